@@ -0,0 +1,44 @@
+package ast
+
+import (
+	"bytes"
+
+	"github.com/pannagaperumal/moxy/internal/token"
+)
+
+// BranchStatement is a break or continue, optionally naming the loop it
+// applies to.
+type BranchStatement struct {
+	Token token.Token // the 'break' or 'continue' token
+	Label *Identifier // nil for the innermost loop
+}
+
+func (bs *BranchStatement) statementNode()       {}
+func (bs *BranchStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BranchStatement) Pos() token.Position  { return bs.Token.Pos }
+func (bs *BranchStatement) String() string {
+	if bs.Label == nil {
+		return bs.TokenLiteral() + ";"
+	}
+	return bs.TokenLiteral() + " " + bs.Label.String() + ";"
+}
+
+// LabeledStatement is a for or while loop with a label that break and
+// continue statements inside it can name.
+type LabeledStatement struct {
+	Token     token.Token // the label's identifier token
+	Label     *Identifier
+	Statement Statement
+}
+
+func (ls *LabeledStatement) statementNode()       {}
+func (ls *LabeledStatement) TokenLiteral() string { return ls.Token.Literal }
+func (ls *LabeledStatement) Pos() token.Position  { return ls.Token.Pos }
+func (ls *LabeledStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(ls.Label.String() + ": ")
+	out.WriteString(ls.Statement.String())
+
+	return out.String()
+}
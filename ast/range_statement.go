@@ -0,0 +1,38 @@
+package ast
+
+import (
+	"bytes"
+
+	"github.com/pannagaperumal/moxy/internal/token"
+)
+
+// RangeStatement is a for loop over the elements of an array, hash, string
+// or iterable host value: for key, value := range Iterable { Body }. Key and
+// Value are nil when omitted.
+type RangeStatement struct {
+	Token    token.Token // the 'for' token
+	Key      *Identifier
+	Value    *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (rs *RangeStatement) statementNode()       {}
+func (rs *RangeStatement) TokenLiteral() string { return rs.Token.Literal }
+func (rs *RangeStatement) Pos() token.Position  { return rs.Token.Pos }
+func (rs *RangeStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for ")
+	if rs.Key != nil {
+		out.WriteString(rs.Key.String())
+		if rs.Value != nil {
+			out.WriteString(", " + rs.Value.String())
+		}
+		out.WriteString(" := ")
+	}
+	out.WriteString("range " + rs.Iterable.String() + " ")
+	out.WriteString(rs.Body.String())
+
+	return out.String()
+}
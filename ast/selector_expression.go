@@ -0,0 +1,19 @@
+package ast
+
+import (
+	"github.com/pannagaperumal/moxy/internal/token"
+)
+
+// SelectorExpression is a member access such as order.total.
+type SelectorExpression struct {
+	Token    token.Token // The . token
+	Left     Expression
+	Property *Identifier
+}
+
+func (se *SelectorExpression) expressionNode()      {}
+func (se *SelectorExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SelectorExpression) Pos() token.Position  { return se.Token.Pos }
+func (se *SelectorExpression) String() string {
+	return "(" + se.Left.String() + "." + se.Property.String() + ")"
+}
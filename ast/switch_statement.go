@@ -0,0 +1,88 @@
+package ast
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/pannagaperumal/moxy/internal/token"
+)
+
+// SwitchStatement runs the first case whose value equals Tag or, when Tag is
+// nil, the first case whose condition is true. In a type switch Tag is a
+// *TypeSwitchGuard, the case values are type names, and Binding, if set,
+// names the guarded value.
+type SwitchStatement struct {
+	Token   token.Token // the 'switch' token
+	Binding *Identifier
+	Tag     Expression
+	Cases   []*CaseClause
+}
+
+func (ss *SwitchStatement) statementNode()       {}
+func (ss *SwitchStatement) TokenLiteral() string { return ss.Token.Literal }
+func (ss *SwitchStatement) Pos() token.Position  { return ss.Token.Pos }
+func (ss *SwitchStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("switch ")
+	if ss.Binding != nil {
+		out.WriteString(ss.Binding.String() + " := ")
+	}
+	if ss.Tag != nil {
+		out.WriteString(ss.Tag.String() + " ")
+	}
+	out.WriteString("{ ")
+	for _, c := range ss.Cases {
+		out.WriteString(c.String() + " ")
+	}
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// IsTypeSwitch reports whether ss switches on the type of a value.
+func (ss *SwitchStatement) IsTypeSwitch() bool {
+	_, ok := ss.Tag.(*TypeSwitchGuard)
+	return ok
+}
+
+// CaseClause is a case of a switch statement, or its default when Values is
+// nil.
+type CaseClause struct {
+	Token       token.Token // the 'case' or 'default' token
+	Values      []Expression
+	Body        *BlockStatement
+	Fallthrough bool // the body ends in fallthrough
+}
+
+func (cc *CaseClause) Pos() token.Position { return cc.Token.Pos }
+func (cc *CaseClause) String() string {
+	var out bytes.Buffer
+
+	if cc.Values == nil {
+		out.WriteString("default:")
+	} else {
+		values := []string{}
+		for _, v := range cc.Values {
+			values = append(values, v.String())
+		}
+		out.WriteString("case " + strings.Join(values, ", ") + ":")
+	}
+	out.WriteString(" " + cc.Body.String())
+	if cc.Fallthrough {
+		out.WriteString(" fallthrough;")
+	}
+
+	return out.String()
+}
+
+// TypeSwitchGuard is the x.(type) in the header of a type switch.
+type TypeSwitchGuard struct {
+	Token token.Token // the '.' token
+	X     Expression
+}
+
+func (tg *TypeSwitchGuard) expressionNode()      {}
+func (tg *TypeSwitchGuard) TokenLiteral() string { return tg.Token.Literal }
+func (tg *TypeSwitchGuard) Pos() token.Position  { return tg.X.Pos() }
+func (tg *TypeSwitchGuard) String() string       { return tg.X.String() + ".(type)" }
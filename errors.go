@@ -0,0 +1,144 @@
+package moxy
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/pannagaperumal/moxy/internal/diag"
+	"github.com/pannagaperumal/moxy/internal/parser"
+	"github.com/pannagaperumal/moxy/internal/vm"
+	"github.com/pannagaperumal/moxy/types"
+)
+
+// Position is a place in a script: file name, and 1-based line and column.
+type Position = types.Position
+
+// Diagnostic is a message about the script at a position.
+type Diagnostic = diag.Diagnostic
+
+// StackFrame is one script call in a RuntimeError's or LimitError's stack.
+type StackFrame = types.StackFrame
+
+// SyntaxError is returned when a script cannot be parsed. It lists every
+// problem the parser found.
+type SyntaxError struct {
+	Diagnostics []*Diagnostic
+	source      string
+}
+
+func (e *SyntaxError) Error() string {
+	msgs := make([]string, len(e.Diagnostics))
+	for i, d := range e.Diagnostics {
+		msgs[i] = diag.Format(e.source, d.Pos, d.Message)
+	}
+	return "syntax error: " + strings.Join(msgs, "\n")
+}
+
+// CompileError is returned when a parsed script cannot be compiled for the
+// VM, for example because it uses an undefined variable.
+type CompileError struct {
+	Pos     Position
+	Message string
+	source  string
+}
+
+func (e *CompileError) Error() string {
+	return "compile error: " + diag.Format(e.source, e.Pos, e.Message)
+}
+
+// RuntimeError is returned when a script fails while running, on either
+// engine. Stack lists the script functions that were running, innermost
+// first. Err is the underlying Go error, if any; when the failure came from a
+// host function it is the error that function returned, so errors.Is and
+// errors.As see through the script.
+type RuntimeError struct {
+	Pos     Position
+	Message string
+	Stack   []StackFrame
+	Err     error
+	source  string
+}
+
+func (e *RuntimeError) Error() string {
+	return "runtime error: " + diag.Format(e.source, e.Pos, e.Message)
+}
+
+func (e *RuntimeError) Unwrap() error { return e.Err }
+
+// StackTrace formats Stack one frame per line, innermost first:
+//
+//	at price_of (plugin.pb:12:16)
+//	at <main> (plugin.pb:30:9)
+func (e *RuntimeError) StackTrace() string {
+	return types.FormatStack(e.Stack)
+}
+
+// LimitError is returned when a run is stopped for going over its Limits or
+// because its context was done. Err is one of ErrBudgetExceeded, ErrMaxDepth,
+// ErrStackOverflow and ErrMemoryLimit, possibly wrapped, or an
+// *InterruptError.
+type LimitError struct {
+	Pos    Position
+	Stack  []StackFrame
+	Err    error
+	source string
+}
+
+func (e *LimitError) Error() string {
+	return "limit exceeded: " + diag.Format(e.source, e.Pos, e.Err.Error())
+}
+
+func (e *LimitError) Unwrap() error { return e.Err }
+
+// StackTrace formats Stack like RuntimeError.StackTrace.
+func (e *LimitError) StackTrace() string {
+	return types.FormatStack(e.Stack)
+}
+
+func syntaxError(src string, p *parser.Parser) error {
+	return &SyntaxError{Diagnostics: p.Diagnostics(), source: src}
+}
+
+func compileError(src string, err error) error {
+	var d *diag.Diagnostic
+	if errors.As(err, &d) {
+		return &CompileError{Pos: d.Pos, Message: d.Message, source: src}
+	}
+	return &CompileError{Message: err.Error(), source: src}
+}
+
+// vmError converts an error from the VM. src may be empty when the failing
+// code came from an earlier run.
+func vmError(src string, err error) error {
+	var rerr *vm.RuntimeError
+	if !errors.As(err, &rerr) {
+		return scriptError(src, Position{}, nil, err.Error(), err)
+	}
+	return scriptError(src, rerr.Pos, rerr.Stack, rerr.Err.Error(), rerr.Err)
+}
+
+// evalError converts an evaluator error object, or returns nil if result is
+// not one.
+func evalError(src string, result types.Object) error {
+	errObj, ok := result.(*types.Error)
+	if !ok {
+		return nil
+	}
+	return scriptError(src, errObj.Pos, errObj.Stack, errObj.Message, errObj.Err)
+}
+
+// scriptError builds a *LimitError when cause is a limit or interrupt and a
+// *RuntimeError otherwise.
+func scriptError(src string, pos Position, stack []StackFrame, msg string, cause error) error {
+	if isLimit(cause) {
+		return &LimitError{Pos: pos, Stack: stack, Err: cause, source: src}
+	}
+	return &RuntimeError{Pos: pos, Message: msg, Stack: stack, Err: cause, source: src}
+}
+
+func isLimit(err error) bool {
+	var ierr *InterruptError
+	return errors.Is(err, ErrBudgetExceeded) || errors.Is(err, ErrMaxDepth) ||
+		errors.Is(err, ErrStackOverflow) || errors.Is(err, ErrMemoryLimit) ||
+		errors.As(err, &ierr)
+}
@@ -12,6 +12,7 @@ import (
 
 func main() {
 	pluginsDir := flag.String("dir", "./examples/plugin_example/plugins", "Directory to search for .pb plugins")
+	useVM := flag.Bool("vm", false, "Run plugins on the bytecode VM instead of the evaluator")
 	flag.Parse()
 
 	fmt.Printf("=== Moxy Lua-style Plugin Host (Directory: %s) ===\n", *pluginsDir)
@@ -39,7 +40,12 @@ func main() {
 			})
 
 			// Run the script
-			_, err := L.RunFile(path)
+			run, call := L.RunFile, L.Call
+			if *useVM {
+				run, call = L.RunVMFile, L.CallVM
+			}
+
+			_, err := run(path)
 			if err != nil {
 				fmt.Printf("Error running %s: %v\n", path, err)
 				continue
@@ -51,7 +57,7 @@ func main() {
 				"message": "This is a very long message that should trigger the notification",
 			}
 
-			result, err := L.Call("on_event", event)
+			result, err := call("on_event", event)
 			if err != nil {
 				fmt.Printf("  Failed to call on_event: %v\n", err)
 			} else {
@@ -42,6 +42,24 @@ const (
 	OpReturn
 	OpPop
 	OpClosure
+	OpGetProperty
+	OpSetProperty
+	OpSetIndex
+	OpSetFree
+	OpCaptureLocal
+	OpCaptureFree
+	OpCurrentClosure
+	OpBitAnd
+	OpBitOr
+	OpBitXor
+	OpBitAndNot
+	OpShiftLeft
+	OpShiftRight
+	OpIterInit
+	OpIterNext
+	OpCaseJump
+	OpJumpTable
+	OpTypeOf
 )
 
 type Definition struct {
@@ -73,7 +91,7 @@ var definitions = map[Opcode]*Definition{
 	OpGetGlobal:      {"OpGetGlobal", []int{2}},
 	OpSetLocal:       {"OpSetLocal", []int{1}},
 	OpGetLocal:       {"OpGetLocal", []int{1}},
-	OpGetBuiltin:     {"OpGetBuiltin", []int{1}},
+	OpGetBuiltin:     {"OpGetBuiltin", []int{2}},
 	OpGetFree:        {"OpGetFree", []int{1}},
 	OpArray:          {"OpArray", []int{2}},
 	OpHash:           {"OpHash", []int{2}},
@@ -83,6 +101,24 @@ var definitions = map[Opcode]*Definition{
 	OpReturn:         {"OpReturn", []int{}},
 	OpPop:            {"OpPop", []int{}},
 	OpClosure:        {"OpClosure", []int{2, 1}},
+	OpGetProperty:    {"OpGetProperty", []int{2}},
+	OpSetProperty:    {"OpSetProperty", []int{2}},
+	OpSetIndex:       {"OpSetIndex", []int{}},
+	OpSetFree:        {"OpSetFree", []int{1}},
+	OpCaptureLocal:   {"OpCaptureLocal", []int{1}},
+	OpCaptureFree:    {"OpCaptureFree", []int{1}},
+	OpCurrentClosure: {"OpCurrentClosure", []int{}},
+	OpBitAnd:         {"OpBitAnd", []int{}},
+	OpBitOr:          {"OpBitOr", []int{}},
+	OpBitXor:         {"OpBitXor", []int{}},
+	OpBitAndNot:      {"OpBitAndNot", []int{}},
+	OpShiftLeft:      {"OpShiftLeft", []int{}},
+	OpShiftRight:     {"OpShiftRight", []int{}},
+	OpIterInit:       {"OpIterInit", []int{}},
+	OpIterNext:       {"OpIterNext", []int{2}},
+	OpCaseJump:       {"OpCaseJump", []int{2}},
+	OpJumpTable:      {"OpJumpTable", []int{2}},
+	OpTypeOf:         {"OpTypeOf", []int{}},
 }
 
 func Lookup(op byte) (*Definition, error) {
@@ -1,8 +1,6 @@
 package compiler
 
 import (
-	"fmt"
-
 	"github.com/pannagaperumal/moxy/ast"
 	"github.com/pannagaperumal/moxy/internal/code"
 	"github.com/pannagaperumal/moxy/internal/symbol"
@@ -10,8 +8,11 @@ import (
 )
 
 func (c *Compiler) compileInfixExpression(node *ast.InfixExpression) error {
-	if node.Operator == "=" {
+	switch node.Operator {
+	case "=":
 		return c.compileAssignment(node)
+	case "&&", "||":
+		return c.compileLogicalExpression(node)
 	}
 
 	err := c.Compile(node.Left)
@@ -47,8 +48,77 @@ func (c *Compiler) compileInfixExpression(node *ast.InfixExpression) error {
 		c.emit(code.OpGreaterOrEqual)
 	case "<=":
 		c.emit(code.OpLessOrEqual)
+	case "&":
+		c.emit(code.OpBitAnd)
+	case "|":
+		c.emit(code.OpBitOr)
+	case "^":
+		c.emit(code.OpBitXor)
+	case "&^":
+		c.emit(code.OpBitAndNot)
+	case "<<":
+		c.emit(code.OpShiftLeft)
+	case ">>":
+		c.emit(code.OpShiftRight)
 	default:
-		return fmt.Errorf("unknown operator %s", node.Operator)
+		return c.errorf("unknown operator %s", node.Operator)
+	}
+	return nil
+}
+
+// compileLogicalExpression compiles && and || so that the right operand is
+// only evaluated when it decides the result. Either way the result is a
+// boolean:
+//
+//	a && b                      a || b
+//	  <a>                         <a>
+//	  OpJumpNotTruthy false       OpJumpNotTruthy right
+//	  <b>                         OpTrue
+//	  OpJumpNotTruthy false       OpJump end
+//	  OpTrue                    right:
+//	  OpJump end                  <b>
+//	false:                        OpJumpNotTruthy false
+//	  OpFalse                     OpTrue
+//	end:                          OpJump end
+//	                            false:
+//	                              OpFalse
+//	                            end:
+func (c *Compiler) compileLogicalExpression(node *ast.InfixExpression) error {
+	err := c.Compile(node.Left)
+	if err != nil {
+		return err
+	}
+
+	var jumpsToEnd []int
+	jumpToFalse := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if node.Operator == "||" {
+		c.emit(code.OpTrue)
+		jumpsToEnd = append(jumpsToEnd, c.emit(code.OpJump, 9999))
+		c.changeOperand(jumpToFalse, len(c.scopes[c.scopeIndex].instructions))
+	}
+
+	err = c.Compile(node.Right)
+	if err != nil {
+		return err
+	}
+
+	jumpsToFalse := []int{c.emit(code.OpJumpNotTruthy, 9999)}
+	if node.Operator == "&&" {
+		jumpsToFalse = append(jumpsToFalse, jumpToFalse)
+	}
+	c.emit(code.OpTrue)
+	jumpsToEnd = append(jumpsToEnd, c.emit(code.OpJump, 9999))
+
+	falsePos := len(c.scopes[c.scopeIndex].instructions)
+	for _, pos := range jumpsToFalse {
+		c.changeOperand(pos, falsePos)
+	}
+	c.emit(code.OpFalse)
+
+	endPos := len(c.scopes[c.scopeIndex].instructions)
+	for _, pos := range jumpsToEnd {
+		c.changeOperand(pos, endPos)
 	}
 	return nil
 }
@@ -65,7 +135,7 @@ func (c *Compiler) compilePrefixExpression(node *ast.PrefixExpression) error {
 	case "-":
 		c.emit(code.OpMinus)
 	default:
-		return fmt.Errorf("unknown operator %s", node.Operator)
+		return c.errorf("unknown operator %s", node.Operator)
 	}
 	return nil
 }
@@ -83,10 +153,7 @@ func (c *Compiler) compileIfExpression(node *ast.IfExpression) error {
 	if err != nil {
 		return err
 	}
-
-	if c.lastInstructionIs(code.OpPop) {
-		c.removeLastPop()
-	}
+	c.leaveBlockValue()
 
 	// Emit an `OpJump` with a bogus value
 	jumpPos := c.emit(code.OpJump, 9999)
@@ -101,10 +168,7 @@ func (c *Compiler) compileIfExpression(node *ast.IfExpression) error {
 		if err != nil {
 			return err
 		}
-
-		if c.lastInstructionIs(code.OpPop) {
-			c.removeLastPop()
-		}
+		c.leaveBlockValue()
 	}
 
 	afterAlternativePos := len(c.scopes[c.scopeIndex].instructions)
@@ -112,6 +176,18 @@ func (c *Compiler) compileIfExpression(node *ast.IfExpression) error {
 	return nil
 }
 
+// leaveBlockValue makes a just-compiled branch of an if leave its value on
+// the stack. A branch that ends in an expression keeps that value instead of
+// popping it; one that is empty or ends in a statement with no value, such as
+// an assignment, yields null.
+func (c *Compiler) leaveBlockValue() {
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+}
+
 func (c *Compiler) compileArrayLiteral(node *ast.ArrayLiteral) error {
 	for _, elem := range node.Elements {
 		err := c.Compile(elem)
@@ -125,12 +201,7 @@ func (c *Compiler) compileArrayLiteral(node *ast.ArrayLiteral) error {
 }
 
 func (c *Compiler) compileHashLiteral(node *ast.HashLiteral) error {
-	keys := []ast.Expression{}
-	for k := range node.Pairs {
-		keys = append(keys, k)
-	}
-
-	for _, k := range keys {
+	for _, k := range node.Keys {
 		err := c.Compile(k)
 		if err != nil {
 			return err
@@ -161,9 +232,24 @@ func (c *Compiler) compileIndexExpression(node *ast.IndexExpression) error {
 	return nil
 }
 
+func (c *Compiler) compileSelectorExpression(node *ast.SelectorExpression) error {
+	err := c.Compile(node.Left)
+	if err != nil {
+		return err
+	}
+
+	name := &types.String{Value: node.Property.Value}
+	c.emit(code.OpGetProperty, c.addConstant(name))
+	return nil
+}
+
 func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral) error {
 	c.enterScope()
 
+	if node.Name != "" {
+		c.symbolTable.DefineFunctionName(node.Name)
+	}
+
 	for _, p := range node.Parameters {
 		c.symbolTable.Define(p.Value)
 	}
@@ -189,18 +275,30 @@ func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral) error {
 		numLocals = c.symbolTable.NumDefinitions()
 	}
 
-	instructions := c.leaveScope()
+	instructions, positions := c.leaveScope()
 
-	// Load all free variables
+	// Capture all free variables by reference: a local of the enclosing
+	// function gets a fresh or shared upvalue, a free variable of the
+	// enclosing function passes its upvalue on, and the enclosing
+	// function's own name captures its closure.
 	for _, s := range freeSymbols {
-		c.loadSymbol(s)
+		switch s.Scope {
+		case symbol.LocalScope:
+			c.emit(code.OpCaptureLocal, s.Index)
+		case symbol.FreeScope:
+			c.emit(code.OpCaptureFree, s.Index)
+		case symbol.FunctionScope:
+			c.emit(code.OpCurrentClosure)
+		}
 	}
 
 	// Create compiled function
 	compiledFn := &types.CompiledFunction{
+		Name:          node.Name,
 		Instructions:  instructions,
 		NumLocals:     numLocals,
 		NumParameters: len(node.Parameters),
+		Positions:     positions,
 	}
 
 	// Add the compiled function to constants and emit closure
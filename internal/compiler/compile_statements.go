@@ -3,10 +3,14 @@ package compiler
 import (
 	"github.com/pannagaperumal/moxy/ast"
 	"github.com/pannagaperumal/moxy/internal/code"
+	"github.com/pannagaperumal/moxy/internal/diag"
 	"github.com/pannagaperumal/moxy/internal/symbol"
+	"github.com/pannagaperumal/moxy/internal/token"
+	"github.com/pannagaperumal/moxy/types"
 )
 
 func (c *Compiler) compileProgram(node *ast.Program) error {
+	c.hoistFunctions(node.Statements)
 	for _, s := range node.Statements {
 		err := c.Compile(s)
 		if err != nil {
@@ -22,9 +26,12 @@ func (c *Compiler) compileExpressionStatement(node *ast.ExpressionStatement) err
 		return err
 	}
 
-	// If the last instruction was an assignment (SetGlobal/SetLocal),
-	// it already popped the value, so we don't need another OpPop.
-	if c.lastInstructionIs(code.OpSetGlobal) || c.lastInstructionIs(code.OpSetLocal) {
+	// If the last instruction was an assignment (SetGlobal/SetLocal/SetFree/
+	// SetProperty/SetIndex), it already popped the value, so we don't need
+	// another OpPop.
+	if c.lastInstructionIs(code.OpSetGlobal) || c.lastInstructionIs(code.OpSetLocal) ||
+		c.lastInstructionIs(code.OpSetFree) || c.lastInstructionIs(code.OpSetProperty) ||
+		c.lastInstructionIs(code.OpSetIndex) {
 		return nil
 	}
 
@@ -33,6 +40,7 @@ func (c *Compiler) compileExpressionStatement(node *ast.ExpressionStatement) err
 }
 
 func (c *Compiler) compileBlockStatement(node *ast.BlockStatement) error {
+	c.hoistFunctions(node.Statements)
 	for _, s := range node.Statements {
 		err := c.Compile(s)
 		if err != nil {
@@ -48,7 +56,10 @@ func (c *Compiler) compileVarStatement(node *ast.VarStatement) error {
 		return err
 	}
 
-	sym := c.symbolTable.Define(node.Name.Value)
+	sym, ok := c.hoisted[node]
+	if !ok {
+		sym = c.symbolTable.Define(node.Name.Value)
+	}
 
 	if sym.Scope == symbol.GlobalScope {
 		c.emit(code.OpSetGlobal, sym.Index)
@@ -68,7 +79,7 @@ func (c *Compiler) compileReturnStatement(node *ast.ReturnStatement) error {
 	return nil
 }
 
-func (c *Compiler) compileForStatement(node *ast.ForStatement) error {
+func (c *Compiler) compileForStatement(node *ast.ForStatement, label string) error {
 	if node.Init != nil {
 		err := c.Compile(node.Init)
 		if err != nil {
@@ -87,11 +98,13 @@ func (c *Compiler) compileForStatement(node *ast.ForStatement) error {
 		jumpNotTruthyPos = c.emit(code.OpJumpNotTruthy, 9999)
 	}
 
+	l := c.enterLoop(label)
 	err := c.Compile(node.Body)
 	if err != nil {
 		return err
 	}
 
+	postPos := len(c.scopes[c.scopeIndex].instructions)
 	if node.Post != nil {
 		err := c.Compile(node.Post)
 		if err != nil {
@@ -105,7 +118,426 @@ func (c *Compiler) compileForStatement(node *ast.ForStatement) error {
 	if jumpNotTruthyPos != -1 {
 		c.changeOperand(jumpNotTruthyPos, afterLoopPos)
 	}
+	c.leaveLoop(l, postPos, afterLoopPos)
+
+	// A for statement has no value. Like an expression statement it
+	// yields null and pops it, leaving the stack as it found it.
+	c.emit(code.OpNull)
+	c.emit(code.OpPop)
+	return nil
+}
+
+// compileRangeStatement compiles a range loop. The iterator stays on the
+// stack while the loop runs:
+//
+//	  <iterable>
+//	  OpIterInit
+//	next:
+//	  OpIterNext end      pushes the key and value, or jumps when done
+//	  <set value>
+//	  <set key>
+//	  <body>
+//	  OpJump next
+//	end:
+//	  OpPop               the iterator
+func (c *Compiler) compileRangeStatement(node *ast.RangeStatement, label string) error {
+	err := c.Compile(node.Iterable)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpIterInit)
+
+	// As in the evaluator, the loop variables are only visible in the loop.
+	c.enterBlock()
+	defer c.leaveBlock()
+
+	nextPos := c.emit(code.OpIterNext, 9999)
+	c.setLoopVariable(node.Value)
+	c.setLoopVariable(node.Key)
+
+	l := c.enterLoop(label)
+	l.iterator = true
+	err = c.Compile(node.Body)
+	if err != nil {
+		return err
+	}
+
+	c.emit(code.OpJump, nextPos)
+
+	endPos := len(c.scopes[c.scopeIndex].instructions)
+	c.changeOperand(nextPos, endPos)
+	c.leaveLoop(l, nextPos, endPos)
+	c.emit(code.OpPop)
+
+	// As with a for statement, yield null and pop it.
+	c.emit(code.OpNull)
+	c.emit(code.OpPop)
+	return nil
+}
+
+// setLoopVariable stores the value on top of the stack in a range loop
+// variable, defining it if needed, or drops it if the variable is omitted or
+// the blank identifier _.
+func (c *Compiler) setLoopVariable(ident *ast.Identifier) {
+	if ident == nil || ident.Value == "_" {
+		c.emit(code.OpPop)
+		return
+	}
+
+	sym := c.symbolTable.Define(ident.Value)
+	if sym.Scope == symbol.GlobalScope {
+		c.emit(code.OpSetGlobal, sym.Index)
+	} else {
+		c.emit(code.OpSetLocal, sym.Index)
+	}
+}
+
+func (c *Compiler) compileWhileExpression(node *ast.WhileExpression, label string) error {
+	loopStart := len(c.scopes[c.scopeIndex].instructions)
+
+	err := c.Compile(node.Condition)
+	if err != nil {
+		return err
+	}
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	l := c.enterLoop(label)
+	err = c.Compile(node.Body)
+	if err != nil {
+		return err
+	}
+
+	c.emit(code.OpJump, loopStart)
+
+	afterLoopPos := len(c.scopes[c.scopeIndex].instructions)
+	c.changeOperand(jumpNotTruthyPos, afterLoopPos)
+	c.leaveLoop(l, loopStart, afterLoopPos)
+
+	c.emit(code.OpNull)
+	return nil
+}
+
+func (c *Compiler) compileLabeledStatement(node *ast.LabeledStatement) error {
+	switch stmt := node.Statement.(type) {
+	case *ast.ForStatement:
+		return c.compileForStatement(stmt, node.Label.Value)
+	case *ast.RangeStatement:
+		return c.compileRangeStatement(stmt, node.Label.Value)
+	case *ast.SwitchStatement:
+		return c.compileSwitchStatement(stmt, node.Label.Value)
+	case *ast.ExpressionStatement:
+		if we, ok := stmt.Expression.(*ast.WhileExpression); ok {
+			err := c.compileWhileExpression(we, node.Label.Value)
+			if err != nil {
+				return err
+			}
+			c.emit(code.OpPop)
+			return nil
+		}
+	}
+	return c.errorf("label %s must be on a loop or switch", node.Label.Value)
+}
+
+// compileBranchStatement emits the jump for break or continue. Its target is
+// patched by leaveLoop. A break applies to a loop or switch, a continue only
+// to a loop.
+func (c *Compiler) compileBranchStatement(node *ast.BranchStatement) error {
+	loops := c.scopes[c.scopeIndex].loops
+	isContinue := node.Token.Type == token.CONTINUE
+
+	var target *loop
+	for i := len(loops) - 1; i >= 0; i-- {
+		if isContinue && loops[i].isSwitch {
+			continue
+		}
+		if node.Label == nil || loops[i].label == node.Label.Value {
+			target = loops[i]
+			break
+		}
+	}
+	switch {
+	case target == nil && node.Label == nil && isContinue:
+		return c.errorf("%s is not in a loop", node.Token.Literal)
+	case target == nil && node.Label == nil:
+		return c.errorf("%s is not in a loop or switch", node.Token.Literal)
+	case target == nil:
+		return c.errorf("invalid %s label %s", node.Token.Literal, node.Label.Value)
+	}
+
+	// Jumping out of range loops nested inside the target leaves their
+	// iterators behind, so pop them first.
+	for i := len(loops) - 1; loops[i] != target; i-- {
+		if loops[i].iterator {
+			c.emit(code.OpPop)
+		}
+	}
+
+	pos := c.emit(code.OpJump, 9999)
+	if isContinue {
+		target.continues = append(target.continues, pos)
+	} else {
+		target.breaks = append(target.breaks, pos)
+	}
+	return nil
+}
+
+// minJumpTableCases is the fewest integer cases for which a switch uses a
+// jump table. With fewer, comparing each case is about as fast.
+const minJumpTableCases = 4
+
+// compileSwitchStatement compiles a switch. The tag stays on the stack while
+// the cases are compared, and a matching case pops it before jumping to its
+// clause:
+//
+//	  <tag>
+//	  <case value>
+//	  OpCaseJump clause    pops the value, and the tag if they are equal
+//	  ...                  the other case values
+//	  OpPop                the tag, when no case matched
+//	  OpJump default       or end if there is no default
+//	clause:
+//	  <body>
+//	  OpJump end           unless the clause ends in fallthrough
+//	  ...                  the other clauses, in source order
+//	end:
+//
+// A switch with no tag tests each case with OpJumpNotTruthy instead. A type
+// switch uses the type name of its value, from OpTypeOf, as the tag, and
+// compares it with the object types of each case. A switch whose cases are
+// dense integer literals selects its clause with one OpJumpTable.
+func (c *Compiler) compileSwitchStatement(node *ast.SwitchStatement, label string) error {
+	if node.Binding != nil {
+		// The type switch variable is only visible inside the switch.
+		c.enterBlock()
+		defer c.leaveBlock()
+	}
 
+	l := c.enterLoop(label)
+	l.isSwitch = true
+
+	var (
+		clauseJumps = make([][]int, len(node.Cases)) // jumps to each clause
+		noMatchJump = -1                             // the jump when no case matches
+		table       *types.JumpTable
+		err         error
+	)
+
+	switch {
+	case node.Tag == nil:
+		noMatchJump, err = c.compileCaseConditions(node, clauseJumps)
+	case node.IsTypeSwitch():
+		noMatchJump, err = c.compileTypeCases(node, clauseJumps)
+	default:
+		if table = c.jumpTable(node); table == nil {
+			noMatchJump, err = c.compileCaseValues(node, clauseJumps)
+		} else if err = c.Compile(node.Tag); err == nil {
+			c.emit(code.OpJumpTable, c.addConstant(table))
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	clauseStarts := make([]int, len(node.Cases))
+	var endJumps []int
+	for i, clause := range node.Cases {
+		clauseStarts[i] = len(c.scopes[c.scopeIndex].instructions)
+		err := c.Compile(clause.Body)
+		if err != nil {
+			return err
+		}
+		if !clause.Fallthrough && i < len(node.Cases)-1 {
+			endJumps = append(endJumps, c.emit(code.OpJump, 9999))
+		}
+	}
+
+	endPos := len(c.scopes[c.scopeIndex].instructions)
+	defaultPos := endPos
+	for i, clause := range node.Cases {
+		if clause.Values == nil {
+			defaultPos = clauseStarts[i]
+		}
+		for _, pos := range clauseJumps[i] {
+			c.changeOperand(pos, clauseStarts[i])
+		}
+	}
+	if noMatchJump != -1 {
+		c.changeOperand(noMatchJump, defaultPos)
+	}
+	for _, pos := range endJumps {
+		c.changeOperand(pos, endPos)
+	}
+	if table != nil {
+		c.fillJumpTable(table, node, clauseStarts, defaultPos)
+	}
+	c.leaveLoop(l, endPos, endPos)
+
+	// As with a for statement, yield null and pop it.
 	c.emit(code.OpNull)
+	c.emit(code.OpPop)
 	return nil
 }
+
+// compileCaseValues compiles the comparisons of a switch with a tag,
+// recording the jump of each matching value in clauseJumps. It returns the
+// jump taken when no case matches.
+func (c *Compiler) compileCaseValues(node *ast.SwitchStatement, clauseJumps [][]int) (int, error) {
+	err := c.Compile(node.Tag)
+	if err != nil {
+		return -1, err
+	}
+
+	for i, clause := range node.Cases {
+		for _, value := range clause.Values {
+			err := c.Compile(value)
+			if err != nil {
+				return -1, err
+			}
+			clauseJumps[i] = append(clauseJumps[i], c.emit(code.OpCaseJump, 9999))
+		}
+	}
+
+	c.emit(code.OpPop)
+	return c.emit(code.OpJump, 9999), nil
+}
+
+// compileCaseConditions compiles the tests of a switch with no tag, where
+// the first case with a truthy value matches.
+func (c *Compiler) compileCaseConditions(node *ast.SwitchStatement, clauseJumps [][]int) (int, error) {
+	for i, clause := range node.Cases {
+		for _, value := range clause.Values {
+			err := c.Compile(value)
+			if err != nil {
+				return -1, err
+			}
+			skip := c.emit(code.OpJumpNotTruthy, 9999)
+			clauseJumps[i] = append(clauseJumps[i], c.emit(code.OpJump, 9999))
+			c.changeOperand(skip, len(c.scopes[c.scopeIndex].instructions))
+		}
+	}
+
+	return c.emit(code.OpJump, 9999), nil
+}
+
+// compileTypeCases compiles the comparisons of a type switch, first setting
+// its variable, if any, to the value being switched on.
+func (c *Compiler) compileTypeCases(node *ast.SwitchStatement, clauseJumps [][]int) (int, error) {
+	guard := node.Tag.(*ast.TypeSwitchGuard)
+	err := c.Compile(guard.X)
+	if err != nil {
+		return -1, err
+	}
+
+	if node.Binding != nil && node.Binding.Value != "_" {
+		sym := c.symbolTable.Define(node.Binding.Value)
+		if sym.Scope == symbol.GlobalScope {
+			c.emit(code.OpSetGlobal, sym.Index)
+		} else {
+			c.emit(code.OpSetLocal, sym.Index)
+		}
+		c.loadSymbol(sym)
+	}
+	c.emit(code.OpTypeOf)
+
+	for i, clause := range node.Cases {
+		for _, value := range clause.Values {
+			name := value.(*ast.Identifier)
+			objTypes, ok := types.TypeNames[name.Value]
+			if !ok {
+				return -1, diag.Errorf(name.Pos(), "unknown type %s in type switch", name.Value)
+			}
+			for _, t := range objTypes {
+				typeName := &types.String{Value: string(t)}
+				c.emit(code.OpConstant, c.addConstant(typeName))
+				clauseJumps[i] = append(clauseJumps[i], c.emit(code.OpCaseJump, 9999))
+			}
+		}
+	}
+
+	c.emit(code.OpPop)
+	return c.emit(code.OpJump, 9999), nil
+}
+
+// jumpTable returns an empty jump table for node if all its case values are
+// integer literals, there are at least minJumpTableCases of them, and they
+// fill at least half the range between the smallest and the largest.
+// Otherwise it returns nil.
+func (c *Compiler) jumpTable(node *ast.SwitchStatement) *types.JumpTable {
+	var count int
+	var lo, hi int64
+	for _, clause := range node.Cases {
+		for _, value := range clause.Values {
+			v, ok := integerCase(value)
+			if !ok {
+				return nil
+			}
+			if count == 0 || v < lo {
+				lo = v
+			}
+			if count == 0 || v > hi {
+				hi = v
+			}
+			count++
+		}
+	}
+
+	if count < minJumpTableCases || uint64(hi-lo) >= uint64(2*count) {
+		return nil
+	}
+	return &types.JumpTable{Min: lo, Targets: make([]int, hi-lo+1)}
+}
+
+// fillJumpTable points each entry of table at the clause of its value, the
+// first one if a value appears twice, or at defaultPos.
+func (c *Compiler) fillJumpTable(table *types.JumpTable, node *ast.SwitchStatement, clauseStarts []int, defaultPos int) {
+	filled := make([]bool, len(table.Targets))
+	for i, clause := range node.Cases {
+		for _, value := range clause.Values {
+			v, _ := integerCase(value)
+			if !filled[v-table.Min] {
+				table.Targets[v-table.Min] = clauseStarts[i]
+				filled[v-table.Min] = true
+			}
+		}
+	}
+	for i := range table.Targets {
+		if !filled[i] {
+			table.Targets[i] = defaultPos
+		}
+	}
+	table.Default = defaultPos
+}
+
+// integerCase returns the value of a case that is an integer literal,
+// possibly negated.
+func integerCase(value ast.Expression) (int64, bool) {
+	switch value := value.(type) {
+	case *ast.IntegerLiteral:
+		return value.Value, true
+	case *ast.PrefixExpression:
+		if lit, ok := value.Right.(*ast.IntegerLiteral); ok && value.Operator == "-" {
+			return -lit.Value, true
+		}
+	}
+	return 0, false
+}
+
+func (c *Compiler) enterLoop(label string) *loop {
+	l := &loop{label: label}
+	c.scopes[c.scopeIndex].loops = append(c.scopes[c.scopeIndex].loops, l)
+	return l
+}
+
+// leaveLoop ends l, pointing its continue statements at continuePos and its
+// break statements at breakPos.
+func (c *Compiler) leaveLoop(l *loop, continuePos, breakPos int) {
+	loops := c.scopes[c.scopeIndex].loops
+	c.scopes[c.scopeIndex].loops = loops[:len(loops)-1]
+
+	for _, pos := range l.continues {
+		c.changeOperand(pos, continuePos)
+	}
+	for _, pos := range l.breaks {
+		c.changeOperand(pos, breakPos)
+	}
+}
@@ -1,17 +1,19 @@
 package compiler
 
 import (
-	"fmt"
-
 	"github.com/pannagaperumal/moxy/ast"
 	"github.com/pannagaperumal/moxy/internal/code"
+	"github.com/pannagaperumal/moxy/internal/diag"
 	"github.com/pannagaperumal/moxy/internal/symbol"
+	"github.com/pannagaperumal/moxy/internal/token"
 	"github.com/pannagaperumal/moxy/types"
 )
 
 type Bytecode struct {
 	Instructions code.Instructions
 	Constants    []types.Object
+	Builtins     *types.BuiltinTable // resolves OpGetBuiltin indexes
+	Positions    types.PositionTable // source positions of Instructions
 }
 
 type Compiler struct {
@@ -23,6 +25,19 @@ type Compiler struct {
 	symbolTable         *SymbolTable
 	scopes              []CompilationScope
 	scopeIndex          int
+	builtins            *types.BuiltinTable
+
+	// externalGlobals, when non-nil, collects names the program uses
+	// without defining them; they become globals the host fills in.
+	externalGlobals map[string]symbol.Symbol
+
+	// hoisted holds the symbols of names bound to functions, defined ahead
+	// of their block so functions in one block can call each other.
+	hoisted map[*ast.VarStatement]symbol.Symbol
+
+	// pos is the position of the innermost node being compiled; emitted
+	// instructions and compile errors are attributed to it.
+	pos token.Position
 }
 
 type EmittedInstruction struct {
@@ -34,9 +49,46 @@ type CompilationScope struct {
 	instructions        code.Instructions
 	lastInstruction     EmittedInstruction
 	previousInstruction EmittedInstruction
+	positions           types.PositionTable
+	loops               []*loop // enclosing loops and switches, innermost last
+}
+
+// loop collects the jumps of the break and continue statements in a loop
+// or switch being compiled, to be patched once its end is known.
+type loop struct {
+	label     string
+	breaks    []int
+	continues []int
+	iterator  bool // a range loop, which keeps its iterator on the stack
+	isSwitch  bool // a switch, which continue statements pass through
 }
 
 func New() *Compiler {
+	return NewWithBuiltins(types.NewBuiltinTable())
+}
+
+// NewWithState returns a compiler that continues from the symbol table and
+// constants of an earlier compilation, so globals defined by previous
+// programs stay visible. Builtins registered since then are added unless a
+// global already uses the name.
+func NewWithState(s *SymbolTable, constants []types.Object, builtins *types.BuiltinTable) *Compiler {
+	compiler := NewWithBuiltins(builtins)
+
+	for i, v := range builtins.Definitions() {
+		if _, ok := s.Resolve(v.Name); !ok {
+			s.DefineBuiltin(i, v.Name)
+		}
+	}
+
+	compiler.symbolTable = s
+	compiler.constants = constants
+	return compiler
+}
+
+// NewWithBuiltins returns a compiler that resolves builtin names against
+// builtins. The table travels with the resulting Bytecode so the VM looks up
+// the same functions.
+func NewWithBuiltins(builtins *types.BuiltinTable) *Compiler {
 	mainScope := CompilationScope{
 		instructions:        code.Instructions{},
 		lastInstruction:     EmittedInstruction{},
@@ -45,7 +97,7 @@ func New() *Compiler {
 
 	symbolTable := NewSymbolTable()
 
-	for i, v := range types.Builtins {
+	for i, v := range builtins.Definitions() {
 		symbolTable.DefineBuiltin(i, v.Name)
 	}
 
@@ -55,21 +107,67 @@ func New() *Compiler {
 		scopes:       []CompilationScope{mainScope},
 		scopeIndex:   0,
 		symbolTable:  symbolTable,
+		builtins:     builtins,
+		hoisted:      make(map[*ast.VarStatement]symbol.Symbol),
 	}
 }
 
 func (c *Compiler) Bytecode() *Bytecode {
 	var instructions code.Instructions
+	var positions types.PositionTable
 	if len(c.scopes) > 0 {
 		instructions = c.scopes[c.scopeIndex].instructions
+		positions = c.scopes[c.scopeIndex].positions
 	}
 	return &Bytecode{
 		Instructions: instructions,
 		Constants:    c.constants,
+		Builtins:     c.builtins,
+		Positions:    positions,
+	}
+}
+
+// DeclareExternalGlobals makes the compiler treat undefined identifiers as
+// globals supplied by the host at run time instead of reporting an error.
+func (c *Compiler) DeclareExternalGlobals() {
+	if c.externalGlobals == nil {
+		c.externalGlobals = make(map[string]symbol.Symbol)
 	}
 }
 
+// ExternalGlobals returns the host-supplied globals the program refers to,
+// keyed by name.
+func (c *Compiler) ExternalGlobals() map[string]symbol.Symbol {
+	return c.externalGlobals
+}
+
+// SymbolTable returns the compiler's current symbol table.
+func (c *Compiler) SymbolTable() *SymbolTable {
+	return c.symbolTable
+}
+
+// NumGlobals returns the number of global slots the program needs.
+func (c *Compiler) NumGlobals() int {
+	outermost := c.symbolTable
+	for outermost.Outer != nil {
+		outermost = outermost.Outer
+	}
+	return outermost.NumDefinitions()
+}
+
+// Compile compiles node. Errors are *diag.Diagnostic values pointing at the
+// offending node.
 func (c *Compiler) Compile(node ast.Node) error {
+	outer := c.pos
+	if pos := node.Pos(); pos.IsValid() {
+		c.pos = pos
+	}
+	err := c.compileNode(node)
+	c.pos = outer
+	return err
+}
+
+func (c *Compiler) compileNode(node ast.Node) error {
 	switch node := node.(type) {
 	case *ast.Program:
 		return c.compileProgram(node)
@@ -80,6 +178,9 @@ func (c *Compiler) Compile(node ast.Node) error {
 	case *ast.IntegerLiteral:
 		integer := &types.Integer{Value: node.Value}
 		c.emit(code.OpConstant, c.addConstant(integer))
+	case *ast.FloatLiteral:
+		float := &types.Float{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(float))
 	case *ast.Boolean:
 		if node.Value {
 			c.emit(code.OpTrue)
@@ -95,9 +196,9 @@ func (c *Compiler) Compile(node ast.Node) error {
 	case *ast.VarStatement:
 		return c.compileVarStatement(node)
 	case *ast.Identifier:
-		symbol, ok := c.symbolTable.Resolve(node.Value)
-		if !ok {
-			return fmt.Errorf("undefined variable %s", node.Value)
+		symbol, err := c.resolve(node.Value)
+		if err != nil {
+			return err
 		}
 		c.loadSymbol(symbol)
 	case *ast.StringLiteral:
@@ -109,6 +210,8 @@ func (c *Compiler) Compile(node ast.Node) error {
 		return c.compileHashLiteral(node)
 	case *ast.IndexExpression:
 		return c.compileIndexExpression(node)
+	case *ast.SelectorExpression:
+		return c.compileSelectorExpression(node)
 	case *ast.FunctionLiteral:
 		return c.compileFunctionLiteral(node)
 	case *ast.ReturnStatement:
@@ -116,16 +219,35 @@ func (c *Compiler) Compile(node ast.Node) error {
 	case *ast.CallExpression:
 		return c.compileCallExpression(node)
 	case *ast.ForStatement:
-		return c.compileForStatement(node)
+		return c.compileForStatement(node, "")
+	case *ast.RangeStatement:
+		return c.compileRangeStatement(node, "")
+	case *ast.WhileExpression:
+		return c.compileWhileExpression(node, "")
+	case *ast.SwitchStatement:
+		return c.compileSwitchStatement(node, "")
+	case *ast.LabeledStatement:
+		return c.compileLabeledStatement(node)
+	case *ast.BranchStatement:
+		return c.compileBranchStatement(node)
+	default:
+		return c.errorf("%T is not supported by the compiler", node)
 	}
 
 	return nil
 }
 
 func (c *Compiler) compileAssignment(node *ast.InfixExpression) error {
+	switch target := node.Left.(type) {
+	case *ast.SelectorExpression:
+		return c.compilePropertyAssignment(target, node.Right)
+	case *ast.IndexExpression:
+		return c.compileIndexAssignment(target, node.Right)
+	}
+
 	ident, ok := node.Left.(*ast.Identifier)
 	if !ok {
-		return fmt.Errorf("left-hand side of assignment must be an identifier")
+		return c.errorf("left-hand side of assignment must be an identifier, property or index expression")
 	}
 
 	err := c.Compile(node.Right)
@@ -133,24 +255,126 @@ func (c *Compiler) compileAssignment(node *ast.InfixExpression) error {
 		return err
 	}
 
-	sym, ok := c.symbolTable.Resolve(ident.Value)
-	if !ok {
-		return fmt.Errorf("undefined variable %s", ident.Value)
+	sym, err := c.resolve(ident.Value)
+	if err != nil {
+		return err
 	}
 
-	if sym.Scope == symbol.GlobalScope {
+	switch sym.Scope {
+	case symbol.GlobalScope:
 		c.emit(code.OpSetGlobal, sym.Index)
-	} else {
+	case symbol.LocalScope:
 		c.emit(code.OpSetLocal, sym.Index)
+	case symbol.FreeScope:
+		c.emit(code.OpSetFree, sym.Index)
+	default:
+		return c.errorf("cannot assign to %s", ident.Value)
 	}
 
 	return nil
 }
 
+// compilePropertyAssignment compiles target.name = value. Like other
+// assignments it leaves nothing on the stack.
+func (c *Compiler) compilePropertyAssignment(target *ast.SelectorExpression, value ast.Expression) error {
+	err := c.Compile(target.Left)
+	if err != nil {
+		return err
+	}
+
+	err = c.Compile(value)
+	if err != nil {
+		return err
+	}
+
+	name := &types.String{Value: target.Property.Value}
+	c.emit(code.OpSetProperty, c.addConstant(name))
+	return nil
+}
+
+// compileIndexAssignment compiles target[index] = value, leaving nothing on
+// the stack.
+func (c *Compiler) compileIndexAssignment(target *ast.IndexExpression, value ast.Expression) error {
+	err := c.Compile(target.Left)
+	if err != nil {
+		return err
+	}
+
+	err = c.Compile(target.Index)
+	if err != nil {
+		return err
+	}
+
+	err = c.Compile(value)
+	if err != nil {
+		return err
+	}
+
+	c.emit(code.OpSetIndex)
+	return nil
+}
+
+// hoistFunctions defines the names bound to functions among statements,
+// whether by `func name() {}` or by `var name = func() {}`, before any of
+// them is compiled. Like other locals and globals they are captured by
+// reference, so a function can call one declared after it in the same block,
+// as it can in the evaluator.
+//
+// Only the first binding of a name in the block is hoisted, and only when
+// the name is not already defined in the current scope; a later `var` of the
+// same name defines a new variable when it runs, as it would without
+// hoisting.
+func (c *Compiler) hoistFunctions(statements []ast.Statement) {
+	seen := make(map[string]bool)
+	for _, s := range statements {
+		stmt, ok := s.(*ast.VarStatement)
+		if !ok {
+			continue
+		}
+		name := stmt.Name.Value
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		if _, isFunc := stmt.Value.(*ast.FunctionLiteral); !isFunc {
+			continue
+		}
+		if c.symbolTable.DefinedHere(name) {
+			continue
+		}
+		c.hoisted[stmt] = c.symbolTable.Define(name)
+	}
+}
+
+func (c *Compiler) resolve(name string) (symbol.Symbol, error) {
+	sym, ok := c.symbolTable.Resolve(name)
+	if ok {
+		return sym, nil
+	}
+	if c.externalGlobals == nil {
+		return sym, c.errorf("undefined variable %s", name)
+	}
+
+	sym = c.symbolTable.DefineGlobal(name)
+	c.externalGlobals[name] = sym
+	return sym, nil
+}
+
+// errorf returns a compile error at the node being compiled.
+func (c *Compiler) errorf(format string, a ...any) error {
+	return diag.Errorf(c.pos, format, a...)
+}
+
 func (c *Compiler) emit(op code.Opcode, operands ...int) int {
 	ins := code.Make(op, operands...)
 	pos := c.addInstruction(ins)
 
+	if c.pos.IsValid() {
+		scope := &c.scopes[c.scopeIndex]
+		scope.positions = scope.positions.Add(pos, diag.Position(c.pos))
+	}
+
 	c.setLastInstruction(op, pos)
 
 	return pos
@@ -182,7 +406,9 @@ func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
 }
 
 func (c *Compiler) removeLastPop() {
-	c.scopes[c.scopeIndex].instructions = c.scopes[c.scopeIndex].instructions[:c.scopes[c.scopeIndex].lastInstruction.Position]
+	last := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.scopes[c.scopeIndex].instructions = c.scopes[c.scopeIndex].instructions[:last]
+	c.scopes[c.scopeIndex].positions = c.scopes[c.scopeIndex].positions.Truncate(last)
 	c.scopes[c.scopeIndex].lastInstruction = c.scopes[c.scopeIndex].previousInstruction
 }
 
@@ -218,8 +444,9 @@ func (c *Compiler) enterScope() {
 	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
 }
 
-func (c *Compiler) leaveScope() code.Instructions {
+func (c *Compiler) leaveScope() (code.Instructions, types.PositionTable) {
 	instructions := c.scopes[c.scopeIndex].instructions
+	positions := c.scopes[c.scopeIndex].positions
 	c.scopes = c.scopes[:len(c.scopes)-1]
 	c.scopeIndex--
 
@@ -228,7 +455,17 @@ func (c *Compiler) leaveScope() code.Instructions {
 		c.symbolTable = c.symbolTable.Outer
 	}
 
-	return instructions
+	return instructions, positions
+}
+
+// enterBlock opens a block scope in the current function, for names that
+// must not outlive a statement.
+func (c *Compiler) enterBlock() {
+	c.symbolTable = symbol.NewBlockSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveBlock() {
+	c.symbolTable = c.symbolTable.Outer
 }
 
 func (c *Compiler) loadSymbol(s symbol.Symbol) {
@@ -241,6 +478,8 @@ func (c *Compiler) loadSymbol(s symbol.Symbol) {
 		c.emit(code.OpGetBuiltin, s.Index)
 	case symbol.FreeScope:
 		c.emit(code.OpGetFree, s.Index)
+	case symbol.FunctionScope:
+		c.emit(code.OpCurrentClosure)
 	}
 }
 
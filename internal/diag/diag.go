@@ -0,0 +1,90 @@
+// Package diag formats errors that point at a place in a script.
+package diag
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pannagaperumal/moxy/internal/token"
+	"github.com/pannagaperumal/moxy/types"
+)
+
+// Diagnostic is an error at a source position.
+type Diagnostic struct {
+	Pos     types.Position
+	Message string
+}
+
+// Errorf returns a Diagnostic at pos.
+func Errorf(pos token.Position, format string, a ...any) *Diagnostic {
+	return &Diagnostic{Pos: Position(pos), Message: fmt.Sprintf(format, a...)}
+}
+
+// Position converts a token position to the one reported to hosts.
+func Position(pos token.Position) types.Position {
+	return types.Position{File: pos.File, Line: pos.Line, Column: pos.Column}
+}
+
+// Error returns "file:line:col: message", or just the message when the
+// position is unknown.
+func (d *Diagnostic) Error() string {
+	return Prefix(d.Pos, d.Message)
+}
+
+// Prefix puts pos in front of msg when pos is known.
+func Prefix(pos types.Position, msg string) string {
+	if !pos.IsValid() {
+		return msg
+	}
+	return pos.String() + ": " + msg
+}
+
+// Snippet returns the line of src that pos points into, followed by a line
+// with a caret under pos's column:
+//
+//	x := 1 + "a";
+//	       ^
+//
+// Tabs before the column are kept and other characters, however many bytes
+// they take, become one space, so the caret lines up. It returns "" when
+// pos is unknown or outside src.
+func Snippet(src string, pos types.Position) string {
+	if !pos.IsValid() || pos.Column < 1 {
+		return ""
+	}
+
+	lines := strings.Split(src, "\n")
+	if pos.Line > len(lines) {
+		return ""
+	}
+	line := strings.TrimRight(lines[pos.Line-1], "\r")
+
+	var caret strings.Builder
+	col := 1
+	for _, ch := range line {
+		if col == pos.Column {
+			break
+		}
+		if ch == '\t' {
+			caret.WriteByte('\t')
+		} else {
+			caret.WriteByte(' ')
+		}
+		col++
+	}
+	if col < pos.Column {
+		return ""
+	}
+	caret.WriteByte('^')
+
+	return line + "\n" + caret.String()
+}
+
+// Format renders msg at pos with the offending source line and a caret.
+func Format(src string, pos types.Position, msg string) string {
+	out := Prefix(pos, msg)
+	if snippet := Snippet(src, pos); snippet != "" {
+		out += "\n" + snippet
+	}
+	return out
+}
@@ -25,22 +25,12 @@ var Builtins = map[string]*types.Builtin{
 			return &types.String{Value: args[0].Inspect()}
 		},
 	},
-	"len": {
-		Fn: func(args ...types.Object) types.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1",
-					len(args))
-			}
-
-			switch arg := args[0].(type) {
-			case *types.String:
-				return &types.Integer{Value: int64(len(arg.Value))}
-			default:
-				return newError("argument to `len` not supported, got %s",
-					args[0].Type())
-			}
-		},
-	},
+	// Shared with the VM, so both engines measure, list and sort values the
+	// same way.
+	"len":    types.GetBuiltinByName("len"),
+	"keys":   types.GetBuiltinByName("keys"),
+	"values": types.GetBuiltinByName("values"),
+	"sort":   types.GetBuiltinByName("sort"),
 }
 
 func RegisterBuiltins(env *types.Environment) {
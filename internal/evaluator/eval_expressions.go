@@ -30,12 +30,14 @@ func evalBangOperatorExpression(right types.Object) types.Object {
 }
 
 func evalMinusPrefixOperatorExpression(right types.Object) types.Object {
-	if right.Type() != types.INTEGER_OBJ {
+	switch right := right.(type) {
+	case *types.Integer:
+		return &types.Integer{Value: -right.Value}
+	case *types.Float:
+		return &types.Float{Value: -right.Value}
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
-
-	value := right.(*types.Integer).Value
-	return &types.Integer{Value: -value}
 }
 
 func evalInfixExpression(operator string, left, right types.Object) types.Object {
@@ -44,18 +46,23 @@ func evalInfixExpression(operator string, left, right types.Object) types.Object
 		return evalIntegerInfixExpression(operator, left, right)
 	case left.Type() == types.FLOAT_OBJ && right.Type() == types.FLOAT_OBJ:
 		return evalFloatInfixExpression(operator, left, right)
-	case left.Type() == types.FLOAT_OBJ && right.Type() == types.INTEGER_OBJ:
-		rightFloat := &types.Float{Value: float64(right.(*types.Integer).Value)}
-		return evalFloatInfixExpression(operator, left, rightFloat)
-	case left.Type() == types.INTEGER_OBJ && right.Type() == types.FLOAT_OBJ:
-		leftFloat := &types.Float{Value: float64(left.(*types.Integer).Value)}
-		return evalFloatInfixExpression(operator, leftFloat, right)
+	case left.Type() == types.FLOAT_OBJ && right.Type() == types.INTEGER_OBJ,
+		left.Type() == types.INTEGER_OBJ && right.Type() == types.FLOAT_OBJ:
+		return evalMixedNumberInfixExpression(operator, left, right)
 	case left.Type() == types.STRING_OBJ && right.Type() == types.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
+	case left.Type() == types.HOST_OBJ || right.Type() == types.HOST_OBJ:
+		result, err := types.HostBinaryOp(operator, left, right)
+		if err != nil {
+			return &types.Error{Message: err.Error(), Err: err}
+		}
+		return result
 	case operator == "==":
-		return nativeBoolToBooleanObject(left == right)
+		return nativeBoolToBooleanObject(types.Equal(left, right))
 	case operator == "!=":
-		return nativeBoolToBooleanObject(left != right)
+		return nativeBoolToBooleanObject(!types.Equal(left, right))
+	case isOrderingOperator(operator):
+		return evalOrderingExpression(operator, left, right)
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s",
 			left.Type(), operator, right.Type())
@@ -77,11 +84,39 @@ func evalIntegerInfixExpression(operator string, left, right types.Object) types
 	case "*":
 		return &types.Integer{Value: leftVal * rightVal}
 	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
 		return &types.Integer{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newError("modulo by zero")
+		}
+		return &types.Integer{Value: leftVal % rightVal}
+	case "&":
+		return &types.Integer{Value: leftVal & rightVal}
+	case "|":
+		return &types.Integer{Value: leftVal | rightVal}
+	case "^":
+		return &types.Integer{Value: leftVal ^ rightVal}
+	case "&^":
+		return &types.Integer{Value: leftVal &^ rightVal}
+	case "<<", ">>":
+		if rightVal < 0 {
+			return newError("negative shift count: %d", rightVal)
+		}
+		if operator == "<<" {
+			return &types.Integer{Value: leftVal << rightVal}
+		}
+		return &types.Integer{Value: leftVal >> rightVal}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
 		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
 	case "==":
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	case "!=":
@@ -92,6 +127,24 @@ func evalIntegerInfixExpression(operator string, left, right types.Object) types
 	}
 }
 
+// evalLogicalExpression evaluates && and ||, evaluating the right operand
+// only when it decides the result. The result is always a boolean.
+func (e *Evaluator) evalLogicalExpression(node *ast.InfixExpression, env *types.Environment) types.Object {
+	left := e.Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+	if isTruthy(left) == (node.Operator == "||") {
+		return nativeBoolToBooleanObject(isTruthy(left))
+	}
+
+	right := e.Eval(node.Right, env)
+	if isError(right) {
+		return right
+	}
+	return nativeBoolToBooleanObject(isTruthy(right))
+}
+
 func evalStringInfixExpression(operator string, left, right types.Object) types.Object {
 	leftVal := left.(*types.String).Value
 	rightVal := right.(*types.String).Value
@@ -99,6 +152,14 @@ func evalStringInfixExpression(operator string, left, right types.Object) types.
 	switch operator {
 	case "+":
 		return &types.String{Value: leftVal + rightVal}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
 	case "==":
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	case "!=":
@@ -109,13 +170,64 @@ func evalStringInfixExpression(operator string, left, right types.Object) types.
 	}
 }
 
-func evalAssignmentExpression(node *ast.InfixExpression, env *types.Environment) types.Object {
+func isOrderingOperator(operator string) bool {
+	switch operator {
+	case "<", ">", "<=", ">=":
+		return true
+	}
+	return false
+}
+
+// evalOrderingExpression orders values of any type through types.Order, so
+// values that cannot be ordered fail with the same error on both engines.
+func evalOrderingExpression(operator string, left, right types.Object) types.Object {
+	result, err := types.Order(operator, left, right)
+	if err != nil {
+		return &types.Error{Message: err.Error(), Err: err}
+	}
+	return nativeBoolToBooleanObject(result)
+}
+
+// evalMixedNumberInfixExpression applies operator to an integer and a float.
+// Comparisons are exact; arithmetic promotes the integer to a float.
+func evalMixedNumberInfixExpression(operator string, left, right types.Object) types.Object {
+	switch operator {
+	case "==":
+		return nativeBoolToBooleanObject(types.Equal(left, right))
+	case "!=":
+		return nativeBoolToBooleanObject(!types.Equal(left, right))
+	case "<", ">", "<=", ">=":
+		return evalOrderingExpression(operator, left, right)
+	case "+", "-", "*", "/":
+		return evalFloatInfixExpression(operator, toFloat(left), toFloat(right))
+	default:
+		return newError("unknown operator: %s %s %s",
+			left.Type(), operator, right.Type())
+	}
+}
+
+// toFloat converts a number to a float.
+func toFloat(obj types.Object) *types.Float {
+	if i, ok := obj.(*types.Integer); ok {
+		return &types.Float{Value: float64(i.Value)}
+	}
+	return obj.(*types.Float)
+}
+
+func (e *Evaluator) evalAssignmentExpression(node *ast.InfixExpression, env *types.Environment) types.Object {
+	switch target := node.Left.(type) {
+	case *ast.SelectorExpression:
+		return e.evalPropertyAssignment(target, node.Right, env)
+	case *ast.IndexExpression:
+		return e.evalIndexAssignment(target, node.Right, env)
+	}
+
 	ident, ok := node.Left.(*ast.Identifier)
 	if !ok {
-		return newError("left side of assignment must be an identifier")
+		return newError("left side of assignment must be an identifier, property or index expression")
 	}
 
-	val := Eval(node.Right, env)
+	val := e.Eval(node.Right, env)
 	if isError(val) {
 		return val
 	}
@@ -128,52 +240,91 @@ func evalAssignmentExpression(node *ast.InfixExpression, env *types.Environment)
 	return val
 }
 
-func evalIfExpression(ie *ast.IfExpression, env *types.Environment) types.Object {
-	condition := Eval(ie.Condition, env)
+func (e *Evaluator) evalPropertyAssignment(target *ast.SelectorExpression, value ast.Expression, env *types.Environment) types.Object {
+	obj := e.Eval(target.Left, env)
+	if isError(obj) {
+		return obj
+	}
+
+	val := e.Eval(value, env)
+	if isError(val) {
+		return val
+	}
+
+	before := types.SizeOf(obj)
+	if err := types.SetProperty(obj, target.Property.Value, val); err != nil {
+		return &types.Error{Message: err.Error(), Err: err}
+	}
+	return e.grown(before, obj, val)
+}
+
+func (e *Evaluator) evalIndexAssignment(target *ast.IndexExpression, value ast.Expression, env *types.Environment) types.Object {
+	obj := e.Eval(target.Left, env)
+	if isError(obj) {
+		return obj
+	}
+
+	index := e.Eval(target.Index, env)
+	if isError(index) {
+		return index
+	}
+
+	val := e.Eval(value, env)
+	if isError(val) {
+		return val
+	}
+
+	before := types.SizeOf(obj)
+	if err := types.SetIndex(obj, index, val); err != nil {
+		return &types.Error{Message: err.Error(), Err: err}
+	}
+	return e.grown(before, obj, val)
+}
+
+func (e *Evaluator) evalIfExpression(ie *ast.IfExpression, env *types.Environment) types.Object {
+	condition := e.Eval(ie.Condition, env)
 	if isError(condition) {
 		return condition
 	}
 
 	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
+		return e.Eval(ie.Consequence, env)
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
+		return e.Eval(ie.Alternative, env)
 	} else {
 		return NULL
 	}
 }
 
-func evalIdentifier(node *ast.Identifier, env *types.Environment) types.Object {
+func (e *Evaluator) evalIdentifier(node *ast.Identifier, env *types.Environment) types.Object {
 	if val, ok := env.Get(node.Value); ok {
 		return val
 	}
 	return newError("identifier not found: " + node.Value)
 }
 
-func evalHashLiteral(node *ast.HashLiteral, env *types.Environment) types.Object {
-	pairs := make(map[types.HashKey]types.HashPair)
+func (e *Evaluator) evalHashLiteral(node *ast.HashLiteral, env *types.Environment) types.Object {
+	hash := types.NewHash(len(node.Keys))
 
-	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, env)
+	for _, keyNode := range node.Keys {
+		key := e.Eval(keyNode, env)
 		if isError(key) {
 			return key
 		}
 
-		hashKey, ok := key.(types.Hashable)
-		if !ok {
+		if _, ok := types.HashKeyOf(key); !ok {
 			return newError("unusable as hash key: %s", key.Type())
 		}
 
-		value := Eval(valueNode, env)
+		value := e.Eval(node.Pairs[keyNode], env)
 		if isError(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = types.HashPair{Key: key, Value: value}
+		hash.Set(key, value)
 	}
 
-	return &types.Hash{Pairs: pairs}
+	return e.allocated(hash)
 }
 
 func evalIndexExpression(left, index types.Object) types.Object {
@@ -182,6 +333,12 @@ func evalIndexExpression(left, index types.Object) types.Object {
 		return evalArrayIndexExpression(left, index)
 	case left.Type() == types.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
+	case left.Type() == types.HOST_OBJ:
+		result, err := left.(*types.HostObject).Index(index)
+		if err != nil {
+			return &types.Error{Message: err.Error(), Err: err}
+		}
+		return result
 	default:
 		return newError("index operator not supported: %s", left.Type())
 	}
@@ -202,17 +359,16 @@ func evalArrayIndexExpression(array, index types.Object) types.Object {
 func evalHashIndexExpression(hash, index types.Object) types.Object {
 	hashObject := hash.(*types.Hash)
 
-	key, ok := index.(types.Hashable)
-	if !ok {
+	if _, ok := types.HashKeyOf(index); !ok {
 		return newError("unusable as hash key: %s", index.Type())
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	value, ok := hashObject.Get(index)
 	if !ok {
 		return NULL
 	}
 
-	return pair.Value
+	return value
 }
 
 func evalFloatInfixExpression(operator string, left, right types.Object) types.Object {
@@ -232,6 +388,10 @@ func evalFloatInfixExpression(operator string, left, right types.Object) types.O
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
 		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
 	case "==":
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	case "!=":
@@ -1,15 +1,18 @@
 package evaluator
 
 import (
+	"slices"
+
 	"github.com/pannagaperumal/moxy/ast"
+	"github.com/pannagaperumal/moxy/internal/diag"
 	"github.com/pannagaperumal/moxy/types"
 )
 
-func evalProgram(program *ast.Program, env *types.Environment) types.Object {
+func (e *Evaluator) evalProgram(program *ast.Program, env *types.Environment) types.Object {
 	var result types.Object
 
 	for _, statement := range program.Statements {
-		result = Eval(statement, env)
+		result = e.Eval(statement, env)
 
 		switch result := result.(type) {
 		case *types.ReturnValue:
@@ -22,15 +25,15 @@ func evalProgram(program *ast.Program, env *types.Environment) types.Object {
 	return result
 }
 
-func evalBlockStatement(block *ast.BlockStatement, env *types.Environment) types.Object {
+func (e *Evaluator) evalBlockStatement(block *ast.BlockStatement, env *types.Environment) types.Object {
 	var result types.Object
 
 	for _, statement := range block.Statements {
-		result = Eval(statement, env)
+		result = e.Eval(statement, env)
 
 		if result != nil {
 			rt := result.Type()
-			if rt == types.RETURN_VALUE_OBJ || rt == types.ERROR_OBJ {
+			if rt == types.RETURN_VALUE_OBJ || rt == types.ERROR_OBJ || rt == branchObj {
 				return result
 			}
 		}
@@ -39,43 +42,56 @@ func evalBlockStatement(block *ast.BlockStatement, env *types.Environment) types
 	return result
 }
 
-func evalWhileExpression(we *ast.WhileExpression, env *types.Environment) types.Object {
+func (e *Evaluator) evalWhileExpression(we *ast.WhileExpression, env *types.Environment, label string) types.Object {
 	var result types.Object = NULL
 
+	mark := len(e.values)
 	for {
-		condition := Eval(we.Condition, env)
+		e.dropValues(mark, result)
+		condition := e.Eval(we.Condition, env)
 		if isError(condition) {
 			return condition
 		}
 		if !isTruthy(condition) {
 			break
 		}
-		result = Eval(we.Body, env)
-		if isError(result) {
+		result = e.Eval(we.Body, env)
+		if b, ok := result.(*branch); ok {
+			if !b.appliesTo(label) {
+				return b
+			}
+			result = NULL
+			if b.stop {
+				break
+			}
+		} else if isError(result) {
 			return result
-		}
-		if result != nil && result.Type() == types.RETURN_VALUE_OBJ {
+		} else if result != nil && result.Type() == types.RETURN_VALUE_OBJ {
 			return result
 		}
 	}
 	return result
 }
 
-func evalForStatement(fs *ast.ForStatement, env *types.Environment) types.Object {
+func (e *Evaluator) evalForStatement(fs *ast.ForStatement, env *types.Environment, label string) types.Object {
 	// Create a new environment for the for loop if it has an init statement
 	var evaluationEnv *types.Environment
 	if fs.Init != nil {
 		evaluationEnv = types.NewEnclosedEnvironment(env)
-		Eval(fs.Init, evaluationEnv)
+		if init := e.Eval(fs.Init, evaluationEnv); isError(init) {
+			return init
+		}
 	} else {
 		evaluationEnv = env
 	}
 
 	var result types.Object = NULL
 
+	mark := len(e.values)
 	for {
+		e.dropValues(mark, result)
 		if fs.Condition != nil {
-			condition := Eval(fs.Condition, evaluationEnv)
+			condition := e.Eval(fs.Condition, evaluationEnv)
 			if isError(condition) {
 				return condition
 			}
@@ -84,18 +100,217 @@ func evalForStatement(fs *ast.ForStatement, env *types.Environment) types.Object
 			}
 		}
 
-		result = Eval(fs.Body, evaluationEnv)
-		if isError(result) {
+		result = e.Eval(fs.Body, evaluationEnv)
+		if b, ok := result.(*branch); ok {
+			if !b.appliesTo(label) {
+				return b
+			}
+			result = NULL
+			if b.stop {
+				break
+			}
+		} else if isError(result) {
 			return result
-		}
-		if result != nil && result.Type() == types.RETURN_VALUE_OBJ {
+		} else if result != nil && result.Type() == types.RETURN_VALUE_OBJ {
 			return result
 		}
 
 		if fs.Post != nil {
-			Eval(fs.Post, evaluationEnv)
+			if post := e.Eval(fs.Post, evaluationEnv); isError(post) {
+				return post
+			}
+		}
+	}
+
+	return result
+}
+
+func (e *Evaluator) evalRangeStatement(rs *ast.RangeStatement, env *types.Environment, label string) types.Object {
+	iterable := e.Eval(rs.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+	iter, err := types.NewIterator(iterable)
+	if err != nil {
+		return newError("%s", err)
+	}
+
+	// Like the init statement of a for loop, the loop variables live in
+	// an environment of their own.
+	loopEnv := types.NewEnclosedEnvironment(env)
+
+	var result types.Object = NULL
+
+	for {
+		key, value, ok := iter.Next()
+		if !ok {
+			break
+		}
+		bindLoopVariable(loopEnv, rs.Key, key)
+		bindLoopVariable(loopEnv, rs.Value, value)
+
+		result = e.Eval(rs.Body, loopEnv)
+		if b, ok := result.(*branch); ok {
+			if !b.appliesTo(label) {
+				return b
+			}
+			result = NULL
+			if b.stop {
+				break
+			}
+		} else if isError(result) {
+			return result
+		} else if result != nil && result.Type() == types.RETURN_VALUE_OBJ {
+			return result
 		}
 	}
 
 	return result
 }
+
+// bindLoopVariable sets a range loop variable, unless it is omitted or the
+// blank identifier _.
+func bindLoopVariable(env *types.Environment, ident *ast.Identifier, value types.Object) {
+	if ident != nil && ident.Value != "_" {
+		env.Set(ident.Value, value)
+	}
+}
+
+// evalSwitchStatement runs the clause selected by selectCase and, while
+// clauses end in fallthrough, the ones after it. A break that applies to the
+// switch ends it; a continue passes through to the enclosing loop. Like the
+// compiled switch, it has no value of its own.
+func (e *Evaluator) evalSwitchStatement(ss *ast.SwitchStatement, env *types.Environment, label string) types.Object {
+	start, env, errObj := e.selectCase(ss, env)
+	if errObj != nil {
+		return errObj
+	}
+
+	for i := start; i >= 0 && i < len(ss.Cases); i++ {
+		result := e.Eval(ss.Cases[i].Body, env)
+		if b, ok := result.(*branch); ok && b.stop && b.appliesTo(label) {
+			return NULL
+		}
+		if result != nil {
+			rt := result.Type()
+			if rt == types.RETURN_VALUE_OBJ || rt == types.ERROR_OBJ || rt == branchObj {
+				return result
+			}
+		}
+		if !ss.Cases[i].Fallthrough {
+			break
+		}
+	}
+
+	return NULL
+}
+
+// selectCase returns the index of the clause of ss to run, or -1 for none,
+// and the environment to run it in. Cases are tried in order, and their
+// values from left to right, until one matches; the default clause runs if
+// none does. A type switch binds its variable in an environment of its own.
+func (e *Evaluator) selectCase(ss *ast.SwitchStatement, env *types.Environment) (int, *types.Environment, types.Object) {
+	var tag types.Object
+	if guard, ok := ss.Tag.(*ast.TypeSwitchGuard); ok {
+		tag = e.Eval(guard.X, env)
+		if isError(tag) {
+			return -1, env, tag
+		}
+		if ss.Binding != nil && ss.Binding.Value != "_" {
+			env = types.NewEnclosedEnvironment(env)
+			env.Set(ss.Binding.Value, tag)
+		}
+	} else if ss.Tag != nil {
+		tag = e.Eval(ss.Tag, env)
+		if isError(tag) {
+			return -1, env, tag
+		}
+	}
+
+	selected := -1
+	for i, clause := range ss.Cases {
+		if clause.Values == nil {
+			selected = i
+			continue
+		}
+
+		for _, value := range clause.Values {
+			var match bool
+			switch {
+			case ss.IsTypeSwitch():
+				name := value.(*ast.Identifier)
+				objTypes, ok := types.TypeNames[name.Value]
+				if !ok {
+					err := newError("unknown type %s in type switch", name.Value)
+					err.Pos = diag.Position(name.Pos())
+					err.Stack = e.stack(err.Pos)
+					return -1, env, err
+				}
+				match = slices.Contains(objTypes, tag.Type())
+			case ss.Tag == nil:
+				cond := e.Eval(value, env)
+				if isError(cond) {
+					return -1, env, cond
+				}
+				match = isTruthy(cond)
+			default:
+				val := e.Eval(value, env)
+				if isError(val) {
+					return -1, env, val
+				}
+				match = types.Equal(tag, val)
+			}
+			if match {
+				return i, env, nil
+			}
+		}
+	}
+	return selected, env, nil
+}
+
+// evalLabeledStatement runs the loop or switch a label names, so that break
+// and continue statements with the label apply to it.
+func (e *Evaluator) evalLabeledStatement(ls *ast.LabeledStatement, env *types.Environment) types.Object {
+	switch stmt := ls.Statement.(type) {
+	case *ast.ForStatement:
+		return e.evalForStatement(stmt, env, ls.Label.Value)
+	case *ast.RangeStatement:
+		return e.evalRangeStatement(stmt, env, ls.Label.Value)
+	case *ast.SwitchStatement:
+		return e.evalSwitchStatement(stmt, env, ls.Label.Value)
+	case *ast.ExpressionStatement:
+		if we, ok := stmt.Expression.(*ast.WhileExpression); ok {
+			return e.evalWhileExpression(we, env, ls.Label.Value)
+		}
+	}
+	return e.Eval(ls.Statement, env)
+}
+
+// branchObj is the type of a branch. It never reaches script values.
+const branchObj types.ObjectType = "BRANCH"
+
+// branch is the result of a break or continue statement. Like a return
+// value, it stops every enclosing block until it reaches the loop or switch
+// it applies to.
+type branch struct {
+	stop  bool   // break rather than continue
+	label string // "" for the innermost loop
+}
+
+func (b *branch) Type() types.ObjectType { return branchObj }
+func (b *branch) Inspect() string {
+	word := "continue"
+	if b.stop {
+		word = "break"
+	}
+	if b.label == "" {
+		return word
+	}
+	return word + " " + b.label
+}
+
+// appliesTo reports whether b is handled by a loop, or for a break a switch,
+// with the given label.
+func (b *branch) appliesTo(label string) bool {
+	return b.label == "" || b.label == label
+}
@@ -1,46 +1,203 @@
 package evaluator
 
 import (
+	"context"
 	"fmt"
+
 	"github.com/pannagaperumal/moxy/ast"
+	"github.com/pannagaperumal/moxy/internal/diag"
+	"github.com/pannagaperumal/moxy/internal/limits"
+	"github.com/pannagaperumal/moxy/internal/token"
 	"github.com/pannagaperumal/moxy/types"
 )
 
+// The evaluator shares the singletons with the VM and the host API, so values
+// passed in from Go compare and test for truth the same way.
 var (
-	NULL  = &types.Null{}
-	TRUE  = &types.Boolean{Value: true}
-	FALSE = &types.Boolean{Value: false}
+	NULL  = types.NULL
+	TRUE  = types.TRUE
+	FALSE = types.FALSE
 )
 
+// interruptCheckInterval is how many nodes are evaluated between two polls
+// of the Evaluator's context.
+const interruptCheckInterval = 1024
+
+// Evaluator walks the AST for a single run. It carries the run's context and
+// limits so that long-running or deeply recursive scripts are stopped with an
+// error instead of hanging or overflowing the Go stack.
+type Evaluator struct {
+	ctx    context.Context
+	done   <-chan struct{}
+	limits limits.Limits
+
+	ticks  int
+	steps  int64
+	depth  int
+	memory *limits.Accountant
+	halted *types.Error
+
+	// envs and values are the roots the memory accountant measures from:
+	// the environment of each node being evaluated, and the values of the
+	// nodes evaluated under it so far, innermost last.
+	envs   []*types.Environment
+	values []types.Object
+
+	// calls holds the script calls in progress, outermost first, so errors
+	// can carry a stack trace. site is the position of the next call.
+	calls []call
+	site  types.Position
+}
+
+// call is a script function call in progress.
+type call struct {
+	function string
+	site     types.Position // where the function was called from
+}
+
+// New returns an Evaluator that stops when ctx is done.
+func New(ctx context.Context) *Evaluator {
+	return NewWithLimits(ctx, limits.Limits{})
+}
+
+// NewWithLimits returns an Evaluator that stops when ctx is done or when the
+// run goes over lim.
+func NewWithLimits(ctx context.Context, lim limits.Limits) *Evaluator {
+	lim = lim.WithDefaults()
+	e := &Evaluator{
+		ctx:    ctx,
+		done:   ctx.Done(),
+		limits: lim,
+	}
+	e.memory = limits.NewAccountant(lim.MaxMemory, e.liveSize)
+	return e
+}
+
+// Steps returns the number of nodes evaluated so far.
+func (e *Evaluator) Steps() int64 {
+	return e.steps
+}
+
+// PeakAllocated returns the most memory the run was measured to hold.
+func (e *Evaluator) PeakAllocated() int64 {
+	return e.memory.Peak()
+}
+
+// TotalAllocated returns the estimated bytes allocated by the run so far.
+func (e *Evaluator) TotalAllocated() int64 {
+	return e.memory.Total()
+}
+
+// liveSize measures the memory reachable from the environments in use and
+// the values held by nodes still being evaluated.
+func (e *Evaluator) liveSize() int64 {
+	var r types.Reachable
+	for _, env := range e.envs {
+		r.AddEnvironment(env)
+	}
+	for _, obj := range e.values {
+		r.Add(obj)
+	}
+	return r.Size()
+}
+
+// Eval evaluates node without cancellation support.
 func Eval(node ast.Node, env *types.Environment) types.Object {
+	return New(context.Background()).Eval(node, env)
+}
+
+// ApplyFunction calls fn with args without cancellation support.
+func ApplyFunction(fn types.Object, args []types.Object) types.Object {
+	return New(context.Background()).ApplyFunction(fn, args)
+}
+
+// Eval evaluates node in env. The values of the nodes evaluated under node
+// stay on the evaluator's roots until node is done, and its own value until
+// its parent is, so the memory they hold counts as live meanwhile. An error
+// object without a position is given the position of node and the current
+// stack, so errors point at the innermost node that failed.
+func (e *Evaluator) Eval(node ast.Node, env *types.Environment) types.Object {
+	outermost := len(e.envs) == 0
+	mark := len(e.values)
+	e.envs = append(e.envs, env)
+
+	result := e.eval(node, env)
+	if err, ok := result.(*types.Error); ok && !err.Pos.IsValid() {
+		err.Pos = diag.Position(node.Pos())
+		err.Stack = e.stack(err.Pos)
+	}
+
+	e.values = append(e.values[:mark], result)
+	if outermost {
+		e.memory.Measure() // include what the run holds at the end in the peak
+	}
+	e.envs = e.envs[:len(e.envs)-1]
+	return result
+}
+
+// stack returns the calls in progress, innermost first, with pos as the
+// position in the innermost one.
+func (e *Evaluator) stack(pos types.Position) []types.StackFrame {
+	frames := make([]types.StackFrame, 0, len(e.calls))
+	for i := len(e.calls) - 1; i >= 0; i-- {
+		frames = append(frames, types.StackFrame{Function: e.calls[i].function, Pos: pos})
+		pos = e.calls[i].site
+	}
+	return frames
+}
+
+func (e *Evaluator) eval(node ast.Node, env *types.Environment) types.Object {
+	if err := e.step(node); err != nil {
+		return err
+	}
+
 	switch node := node.(type) {
 
 	// Statements
 	case *ast.Program:
-		return evalProgram(node, env)
+		e.calls = append(e.calls, call{function: types.MainFunctionName})
+		result := e.evalProgram(node, env)
+		e.calls = e.calls[:len(e.calls)-1]
+		return result
 
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return e.Eval(node.Expression, env)
 
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
+		return e.evalBlockStatement(node, env)
 
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
+		val := e.Eval(node.ReturnValue, env)
 		if isError(val) {
 			return val
 		}
 		return &types.ReturnValue{Value: val}
 
 	case *ast.VarStatement:
-		val := Eval(node.Value, env)
+		val := e.Eval(node.Value, env)
 		if isError(val) {
 			return val
 		}
 		env.Set(node.Name.Value, val)
 
 	case *ast.ForStatement:
-		return evalForStatement(node, env)
+		return e.evalForStatement(node, env, "")
+
+	case *ast.RangeStatement:
+		return e.evalRangeStatement(node, env, "")
+
+	case *ast.SwitchStatement:
+		return e.evalSwitchStatement(node, env, "")
+
+	case *ast.LabeledStatement:
+		return e.evalLabeledStatement(node, env)
+
+	case *ast.BranchStatement:
+		b := &branch{stop: node.Token.Type == token.BREAK}
+		if node.Label != nil {
+			b.label = node.Label.Value
+		}
+		return b
 
 	// Expressions
 	case *ast.IntegerLiteral:
@@ -56,85 +213,119 @@ func Eval(node ast.Node, env *types.Environment) types.Object {
 		return nativeBoolToBooleanObject(node.Value)
 
 	case *ast.PrefixExpression:
-		right := Eval(node.Right, env)
+		right := e.Eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
 		return evalPrefixExpression(node.Operator, right)
 
 	case *ast.InfixExpression:
-		if node.Operator == "=" {
-			return evalAssignmentExpression(node, env)
+		switch node.Operator {
+		case "=":
+			return e.evalAssignmentExpression(node, env)
+		case "&&", "||":
+			return e.evalLogicalExpression(node, env)
 		}
-		left := Eval(node.Left, env)
+		left := e.Eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
-		right := Eval(node.Right, env)
+		right := e.Eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
-		return evalInfixExpression(node.Operator, left, right)
+		return e.allocated(evalInfixExpression(node.Operator, left, right))
 
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return e.evalIfExpression(node, env)
 
 	case *ast.Identifier:
-		return evalIdentifier(node, env)
+		return e.evalIdentifier(node, env)
 
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &types.Function{Parameters: params, Env: env, Body: body}
+		return &types.Function{Name: node.Name, Parameters: params, Env: env, Body: body}
 
 	case *ast.CallExpression:
-		function := Eval(node.Function, env)
+		function := e.Eval(node.Function, env)
 		if isError(function) {
 			return function
 		}
-		args := evalExpressions(node.Arguments, env)
+		args := e.evalExpressions(node.Arguments, env)
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
 
-		return ApplyFunction(function, args)
+		e.site = diag.Position(node.Pos())
+		return e.ApplyFunction(function, args)
 
 	case *ast.WhileExpression:
-		return evalWhileExpression(node, env)
+		return e.evalWhileExpression(node, env, "")
 
 	case *ast.ArrayLiteral:
-		elements := evalExpressions(node.Elements, env)
+		elements := e.evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
 			return elements[0]
 		}
-		return &types.Array{Elements: elements}
+		return e.allocated(&types.Array{Elements: elements})
 
 	case *ast.HashLiteral:
-		return evalHashLiteral(node, env)
+		return e.evalHashLiteral(node, env)
 
 	case *ast.IndexExpression:
-		left := Eval(node.Left, env)
+		left := e.Eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
-		index := Eval(node.Index, env)
+		index := e.Eval(node.Index, env)
 		if isError(index) {
 			return index
 		}
 		return evalIndexExpression(left, index)
+
+	case *ast.SelectorExpression:
+		left := e.Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		result, err := types.GetProperty(left, node.Property.Value)
+		if err != nil {
+			return &types.Error{Message: err.Error(), Err: err}
+		}
+		return result
 	}
 	return nil
 }
 
-func ApplyFunction(fn types.Object, args []types.Object) types.Object {
+func (e *Evaluator) ApplyFunction(fn types.Object, args []types.Object) types.Object {
 	switch fn := fn.(type) {
 	case *types.Function:
+		if len(args) != len(fn.Parameters) {
+			return newError("wrong number of arguments: want=%d, got=%d",
+				len(fn.Parameters), len(args))
+		}
+		if e.depth >= e.limits.MaxFrames {
+			err := fmt.Errorf("%w (%d frames)", limits.ErrMaxDepth, e.limits.MaxFrames)
+			return &types.Error{Message: err.Error(), Err: err}
+		}
+
+		name := fn.Name
+		if name == "" {
+			name = types.AnonymousFunctionName
+		}
+		e.calls = append(e.calls, call{function: name, site: e.site})
+		e.site = types.Position{}
+
+		e.depth++
 		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
+		evaluated := e.Eval(fn.Body, extendedEnv)
+		e.depth--
+		e.calls = e.calls[:len(e.calls)-1]
 		return unwrapReturnValue(evaluated)
 
 	case *types.Builtin:
-		return fn.Fn(args...)
+		return e.allocated(fn.Fn(args...))
 
 	case *types.Closure:
 		// To call a VM-compiled function from the evaluator, we need to bridge it.
@@ -145,3 +336,69 @@ func ApplyFunction(fn types.Object, args []types.Object) types.Object {
 		return newError("not a function: %s", fn.Type())
 	}
 }
+
+// step accounts for the evaluation of node against the instruction budget and
+// polls the context every interruptCheckInterval nodes. Once a run has been
+// halted every further evaluation fails with the same error, so loops that
+// discard intermediate results still unwind.
+func (e *Evaluator) step(node ast.Node) *types.Error {
+	if e.halted != nil {
+		return e.halted
+	}
+
+	e.steps++
+	if e.limits.MaxInstructions > 0 && e.steps > e.limits.MaxInstructions {
+		err := fmt.Errorf("%w (%d instructions)", limits.ErrBudgetExceeded, e.limits.MaxInstructions)
+		e.halted = &types.Error{Message: err.Error(), Err: err}
+		return e.halted
+	}
+
+	if e.done == nil {
+		return nil
+	}
+
+	e.ticks++
+	if e.ticks < interruptCheckInterval {
+		return nil
+	}
+	e.ticks = 0
+
+	select {
+	case <-e.done:
+		err := &limits.InterruptError{Err: e.ctx.Err(), Location: describeNode(node)}
+		e.halted = &types.Error{Message: err.Error(), Err: err}
+		return e.halted
+	default:
+		return nil
+	}
+}
+
+// dropValues releases the values loop iterations have added to the roots
+// since mark, keeping only result, the value of the last one.
+func (e *Evaluator) dropValues(mark int, result types.Object) {
+	e.values = append(e.values[:mark], result)
+}
+
+// allocated charges a newly built object to the run's memory budget. Going
+// over the budget halts the run like an exhausted instruction budget.
+func (e *Evaluator) allocated(obj types.Object) types.Object {
+	if obj == nil {
+		return nil
+	}
+	if err := e.memory.Charge(obj); err != nil {
+		e.halted = &types.Error{Message: err.Error(), Err: err}
+		return e.halted
+	}
+	return obj
+}
+
+// grown charges the growth of obj, whose size was before, from an assignment
+// of val to the run's memory budget and returns val. Going over the budget
+// halts the run like allocated does.
+func (e *Evaluator) grown(before int64, obj, val types.Object) types.Object {
+	if err := e.memory.ChargeGrowth(before, obj); err != nil {
+		e.halted = &types.Error{Message: err.Error(), Err: err}
+		return e.halted
+	}
+	return val
+}
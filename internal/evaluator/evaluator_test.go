@@ -0,0 +1,97 @@
+package evaluator
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/pannagaperumal/moxy/ast"
+	"github.com/pannagaperumal/moxy/internal/lexer"
+	"github.com/pannagaperumal/moxy/internal/limits"
+	"github.com/pannagaperumal/moxy/internal/parser"
+	"github.com/pannagaperumal/moxy/types"
+)
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		limits   limits.Limits
+		input    string
+		expected error // nil means the program must finish
+	}{
+		{limits.Limits{MaxInstructions: 1000}, `for true {}`, limits.ErrBudgetExceeded},
+		{limits.Limits{MaxInstructions: 1000}, `var n = 0; for n < 10 { n = n + 1 }`, nil},
+		{limits.Limits{}, `var f = func(n) { f(n + 1) }; f(0)`, limits.ErrMaxDepth},
+		{limits.Limits{MaxFrames: 10}, `var f = func(n) { if (n == 0) { return 0 } f(n - 1) }; f(20)`, limits.ErrMaxDepth},
+		{limits.Limits{MaxFrames: 10}, `var f = func(n) { if (n == 0) { return 0 } f(n - 1) }; f(5)`, nil},
+	}
+
+	for i, tt := range tests {
+		e := NewWithLimits(context.Background(), tt.limits)
+		result := e.Eval(parse(t, tt.input), types.NewEnvironment())
+
+		errObj, isErr := result.(*types.Error)
+		switch {
+		case tt.expected == nil && isErr:
+			t.Fatalf("tests[%d] - unexpected error: %s", i, errObj.Message)
+		case tt.expected != nil && (!isErr || !errors.Is(errObj.Err, tt.expected)):
+			t.Fatalf("tests[%d] - expected error %v, got=%s", i, tt.expected, result.Inspect())
+		}
+	}
+}
+
+// An exhausted budget must stop loops that throw away the error result of
+// their body, not just the statement that ran out.
+func TestBudgetStopsEveryLoop(t *testing.T) {
+	input := `var n = 0; for true { for n < 1000000 { n = n + 1 } }`
+
+	e := NewWithLimits(context.Background(), limits.Limits{MaxInstructions: 5000})
+	result := e.Eval(parse(t, input), types.NewEnvironment())
+
+	errObj, ok := result.(*types.Error)
+	if !ok || !errors.Is(errObj.Err, limits.ErrBudgetExceeded) {
+		t.Fatalf("expected ErrBudgetExceeded, got=%s", result.Inspect())
+	}
+	if e.Steps() > 5001 {
+		t.Fatalf("evaluated %d nodes after the budget ran out", e.Steps()-5000)
+	}
+}
+
+func TestMemoryLimit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected error // nil means the program must finish
+	}{
+		{`var s = "x"; for true { s = s + s }`, limits.ErrMemoryLimit},
+		{`var a = []; for true { a = [a, a, "item"] }`, limits.ErrMemoryLimit},
+		{`var fs = []; for true { fs = [fs, func() { fs }] }`, limits.ErrMemoryLimit},
+		// Each iteration drops the previous string, so only the last one is live.
+		{`var s = ""; for i := 0; i < 10000; i = i + 1 { s = s + "x" }`, nil},
+		{`var n = 0; for n < 10000 { var h = {"n": [n, n, n]}; n = n + 1 }`, nil},
+	}
+
+	for i, tt := range tests {
+		e := NewWithLimits(context.Background(), limits.Limits{MaxMemory: 1 << 20})
+		result := e.Eval(parse(t, tt.input), types.NewEnvironment())
+
+		errObj, isErr := result.(*types.Error)
+		switch {
+		case tt.expected == nil && isErr:
+			t.Fatalf("tests[%d] - unexpected error: %s", i, errObj.Message)
+		case tt.expected != nil && (!isErr || !errors.Is(errObj.Err, tt.expected)):
+			t.Fatalf("tests[%d] - expected error %v, got=%s", i, tt.expected, result.Inspect())
+		case tt.expected == nil && e.PeakAllocated() >= e.TotalAllocated():
+			t.Fatalf("tests[%d] - peak %d should stay below total %d", i, e.PeakAllocated(), e.TotalAllocated())
+		}
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return program
+}
@@ -2,6 +2,7 @@ package evaluator
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/pannagaperumal/moxy/ast"
 	"github.com/pannagaperumal/moxy/types"
@@ -38,11 +39,11 @@ func isError(obj types.Object) bool {
 	return false
 }
 
-func evalExpressions(exps []ast.Expression, env *types.Environment) []types.Object {
+func (e *Evaluator) evalExpressions(exps []ast.Expression, env *types.Environment) []types.Object {
 	var result []types.Object
 
-	for _, e := range exps {
-		evaluated := Eval(e, env)
+	for _, exp := range exps {
+		evaluated := e.Eval(exp, env)
 		if isError(evaluated) {
 			return []types.Object{evaluated}
 		}
@@ -68,3 +69,18 @@ func extendFunctionEnv(fn *types.Function, args []types.Object) *types.Environme
 
 	return env
 }
+
+// describeNode gives a short, single-line rendering of node for error
+// messages.
+func describeNode(node ast.Node) string {
+	const maxLen = 40
+
+	desc := strings.Join(strings.Fields(node.String()), " ")
+	if desc == "" {
+		desc = node.TokenLiteral()
+	}
+	if len(desc) > maxLen {
+		desc = desc[:maxLen] + "..."
+	}
+	return fmt.Sprintf("%q", desc)
+}
@@ -28,6 +28,11 @@ if (5 < 10) {
 while (x < 5) {
 	print(x);
 }
+order.item2 = 1.5;
+a <= b >= c % d && e || f & g | h ^ i &^ j << k >> l;
+outer: for { break outer; continue; }
+for k, v := range m {}
+switch v := x.(type) { case int: fallthrough; default: }
 `
 
 	tests := []struct {
@@ -120,6 +125,72 @@ while (x < 5) {
 		{token.RPAREN, ")"},
 		{token.SEMICOLON, ";"},
 		{token.RBRACE, "}"},
+		{token.IDENT, "order"},
+		{token.DOT, "."},
+		{token.IDENT, "item2"},
+		{token.ASSIGN, "="},
+		{token.FLOAT, "1.5"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.LT_EQ, "<="},
+		{token.IDENT, "b"},
+		{token.GT_EQ, ">="},
+		{token.IDENT, "c"},
+		{token.PERCENT, "%"},
+		{token.IDENT, "d"},
+		{token.AND, "&&"},
+		{token.IDENT, "e"},
+		{token.OR, "||"},
+		{token.IDENT, "f"},
+		{token.AMPERSAND, "&"},
+		{token.IDENT, "g"},
+		{token.PIPE, "|"},
+		{token.IDENT, "h"},
+		{token.CARET, "^"},
+		{token.IDENT, "i"},
+		{token.AND_NOT, "&^"},
+		{token.IDENT, "j"},
+		{token.SHL, "<<"},
+		{token.IDENT, "k"},
+		{token.SHR, ">>"},
+		{token.IDENT, "l"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "outer"},
+		{token.COLON, ":"},
+		{token.FOR, "for"},
+		{token.LBRACE, "{"},
+		{token.BREAK, "break"},
+		{token.IDENT, "outer"},
+		{token.SEMICOLON, ";"},
+		{token.CONTINUE, "continue"},
+		{token.SEMICOLON, ";"},
+		{token.RBRACE, "}"},
+		{token.FOR, "for"},
+		{token.IDENT, "k"},
+		{token.COMMA, ","},
+		{token.IDENT, "v"},
+		{token.DECLARE_ASSIGN, ":="},
+		{token.RANGE, "range"},
+		{token.IDENT, "m"},
+		{token.LBRACE, "{"},
+		{token.RBRACE, "}"},
+		{token.SWITCH, "switch"},
+		{token.IDENT, "v"},
+		{token.DECLARE_ASSIGN, ":="},
+		{token.IDENT, "x"},
+		{token.DOT, "."},
+		{token.LPAREN, "("},
+		{token.IDENT, "type"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.CASE, "case"},
+		{token.IDENT, "int"},
+		{token.COLON, ":"},
+		{token.FALLTHROUGH, "fallthrough"},
+		{token.SEMICOLON, ";"},
+		{token.DEFAULT, "default"},
+		{token.COLON, ":"},
+		{token.RBRACE, "}"},
 		{token.EOF, ""},
 	}
 
@@ -139,3 +210,41 @@ while (x < 5) {
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := "var x = 1;\n// note\n\tx == \"a\";\n\"é\" + y"
+
+	tests := []struct {
+		expectedLiteral string
+		expectedPos     token.Position
+	}{
+		{"var", token.Position{File: "a.pb", Line: 1, Column: 1, Offset: 0}},
+		{"x", token.Position{File: "a.pb", Line: 1, Column: 5, Offset: 4}},
+		{"=", token.Position{File: "a.pb", Line: 1, Column: 7, Offset: 6}},
+		{"1", token.Position{File: "a.pb", Line: 1, Column: 9, Offset: 8}},
+		{";", token.Position{File: "a.pb", Line: 1, Column: 10, Offset: 9}},
+		{"x", token.Position{File: "a.pb", Line: 3, Column: 2, Offset: 20}},
+		{"==", token.Position{File: "a.pb", Line: 3, Column: 4, Offset: 22}},
+		{"a", token.Position{File: "a.pb", Line: 3, Column: 7, Offset: 25}},
+		{";", token.Position{File: "a.pb", Line: 3, Column: 10, Offset: 28}},
+		{"é", token.Position{File: "a.pb", Line: 4, Column: 1, Offset: 30}},
+		{"+", token.Position{File: "a.pb", Line: 4, Column: 5, Offset: 35}},
+		{"y", token.Position{File: "a.pb", Line: 4, Column: 7, Offset: 37}},
+	}
+
+	l := NewFile("a.pb", input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Pos != tt.expectedPos {
+			t.Fatalf("tests[%d] - position wrong. expected=%s, got=%s",
+				i, tt.expectedPos, tok.Pos)
+		}
+	}
+}
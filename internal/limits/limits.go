@@ -0,0 +1,70 @@
+// Package limits holds the resource limits and errors shared by the
+// evaluator and the VM, so that neither engine depends on the other.
+package limits
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Defaults used by WithDefaults.
+const (
+	StackSize = 2048
+	MaxFrames = 1024
+)
+
+var (
+	ErrBudgetExceeded = errors.New("instruction budget exceeded")
+	ErrMaxDepth       = errors.New("maximum call depth exceeded")
+	ErrStackOverflow  = errors.New("stack overflow")
+)
+
+// InterruptError is returned when a run is stopped because its context was
+// cancelled or its deadline passed.
+type InterruptError struct {
+	Err      error  // context.Canceled or context.DeadlineExceeded
+	Location string // where the script was when it stopped
+}
+
+func (e *InterruptError) Error() string {
+	return fmt.Sprintf("execution interrupted at %s: %s", e.Location, e.Err)
+}
+
+func (e *InterruptError) Unwrap() error { return e.Err }
+
+// slotsPerFrame is the stack room reserved per frame when MaxStack is derived
+// from MaxFrames: the callee, one argument and a couple of temporaries.
+const slotsPerFrame = 4
+
+// Limits bounds the resources a single run may use. A zero field falls back
+// to its default, so the zero Limits gives an unbounded run with the default
+// stack and call depth.
+type Limits struct {
+	// MaxInstructions caps the number of instructions executed (or AST
+	// nodes evaluated by the evaluator). Zero means unlimited.
+	MaxInstructions int64
+
+	// MaxFrames caps the call depth. Zero means MaxFrames.
+	MaxFrames int
+
+	// MaxStack caps the number of VM stack slots. Zero means StackSize or
+	// enough slots for MaxFrames small calls, whichever is larger. The
+	// evaluator has no value stack and ignores it.
+	MaxStack int
+
+	// MaxMemory caps the estimated bytes of strings, arrays, hashes and
+	// closures a run may hold at once (see Accountant). Zero means
+	// unlimited.
+	MaxMemory int64
+}
+
+// WithDefaults returns a copy of l with zero fields replaced by defaults.
+func (l Limits) WithDefaults() Limits {
+	if l.MaxFrames <= 0 {
+		l.MaxFrames = MaxFrames
+	}
+	if l.MaxStack <= 0 {
+		l.MaxStack = max(StackSize, l.MaxFrames*slotsPerFrame)
+	}
+	return l
+}
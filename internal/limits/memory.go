@@ -0,0 +1,108 @@
+package limits
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/pannagaperumal/moxy/types"
+)
+
+var ErrMemoryLimit = errors.New("memory limit exceeded")
+
+// minMeasureInterval is the fewest bytes charged between two measurements,
+// so small runs are not measured over and over.
+const minMeasureInterval = 64 << 10
+
+// Accountant estimates the memory a run holds in strings, arrays, hashes and
+// closures, and fails the run when it goes over a ceiling. Values are charged
+// when they are built; scalars are not. Values that become garbage are not
+// tracked one by one: once the charges since the last measurement add up,
+// the Accountant measures what the run can still reach, and that replaces
+// its estimate. Memory held by values that were replaced or dropped is
+// credited back then, so a loop that keeps rebuilding a string only holds its
+// latest copy against the ceiling.
+type Accountant struct {
+	limit   int64        // 0 means unlimited
+	measure func() int64 // bytes reachable from the run's roots
+
+	live  int64 // the last measurement plus what was charged since
+	next  int64 // the estimate at which to measure again
+	peak  int64 // the largest measurement
+	total int64 // everything charged, garbage or not
+}
+
+// NewAccountant returns an Accountant that fails once the run holds more than
+// limit bytes, as measured by measure. A limit of zero only measures.
+func NewAccountant(limit int64, measure func() int64) *Accountant {
+	a := &Accountant{limit: limit, measure: measure}
+	a.schedule()
+	return a
+}
+
+// Charge adds the size of obj, a value that was just built, to the estimate.
+func (a *Accountant) Charge(obj types.Object) error {
+	switch obj.(type) {
+	case *types.String, *types.Array, *types.Hash, *types.Closure:
+	default:
+		return nil
+	}
+
+	return a.add(types.SizeOf(obj))
+}
+
+// ChargeGrowth charges an assignment into obj, given the size obj had
+// before it. A new hash key grows obj by an entry, which is charged; the
+// stored value was charged when it was built, and replacing an existing
+// element costs nothing.
+func (a *Accountant) ChargeGrowth(before int64, obj types.Object) error {
+	grown := types.SizeOf(obj) - before
+	if grown <= 0 {
+		return nil
+	}
+	return a.add(grown)
+}
+
+func (a *Accountant) add(n int64) error {
+	a.total += n
+	if a.live+n > a.next {
+		// The value being charged is not reachable yet, so it is added
+		// after measuring.
+		a.Measure()
+	}
+	a.live += n
+	if a.limit > 0 && a.live > a.limit {
+		return fmt.Errorf("%w (%d bytes live, limit %d)", ErrMemoryLimit, a.live, a.limit)
+	}
+	return nil
+}
+
+// Measure replaces the estimate with the size of what the run can reach.
+// The engines also call it when a run ends, so the peak covers small runs
+// that never needed measuring.
+func (a *Accountant) Measure() {
+	a.live = a.measure()
+	a.peak = max(a.peak, a.live)
+	a.schedule()
+}
+
+// schedule sets the next measurement for when the estimate has doubled,
+// or reaches the limit.
+func (a *Accountant) schedule() {
+	a.next = max(2*a.live, minMeasureInterval)
+	if a.limit > 0 {
+		a.next = min(a.next, a.limit)
+	}
+}
+
+// Peak returns the most bytes the run was measured to hold at once.
+// Measurements are taken as charges add up and when the run ends, so a
+// short-lived value built and dropped between two of them may be missed.
+func (a *Accountant) Peak() int64 {
+	return a.peak
+}
+
+// Total returns the bytes charged over the run, including values that have
+// since become garbage.
+func (a *Accountant) Total() int64 {
+	return a.total
+}
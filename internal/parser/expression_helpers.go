@@ -64,6 +64,8 @@ func (p *Parser) parseCallArguments() []ast.Expression {
 
 func (p *Parser) parseWhileExpression() ast.Expression {
 	expression := &ast.WhileExpression{Token: p.curToken}
+	p.enterTarget(true)
+	defer p.leaveTarget()
 
 	// Optional parentheses
 	if p.peekTokenIs(token.LPAREN) {
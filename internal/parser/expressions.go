@@ -8,7 +8,7 @@ import (
 func (p *Parser) parseExpression(precedence int) ast.Expression {
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
-		p.noPrefixParseFnError(p.curToken.Type)
+		p.noPrefixParseFnError(p.curToken)
 		return nil
 	}
 	leftExp := prefix()
@@ -113,3 +113,43 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 
 	return exp
 }
+
+func (p *Parser) parseSelectorExpression(left ast.Expression) ast.Expression {
+	if p.peekTokenIs(token.LPAREN) {
+		return p.parseTypeSwitchGuard(left)
+	}
+
+	exp := &ast.SelectorExpression{Token: p.curToken, Left: left}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	exp.Property = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	return exp
+}
+
+// parseTypeSwitchGuard parses the .(type) of x.(type), which may only be the
+// tag of a switch.
+func (p *Parser) parseTypeSwitchGuard(left ast.Expression) ast.Expression {
+	guard := &ast.TypeSwitchGuard{Token: p.curToken, X: left}
+
+	p.nextToken() // move to '('
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	if p.curToken.Literal != "type" {
+		p.errorf(p.curToken.Pos, "expected type, got %s", p.curToken.Describe())
+		return nil
+	}
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.typeSwitch {
+		p.errorf(guard.Pos(), "use of .(type) outside type switch")
+		return nil
+	}
+	p.typeSwitch = false
+	return guard
+}
@@ -1,8 +1,10 @@
 package parser
 
 import (
-	"fmt"
+	"slices"
+
 	"github.com/pannagaperumal/moxy/ast"
+	"github.com/pannagaperumal/moxy/internal/diag"
 	"github.com/pannagaperumal/moxy/internal/lexer"
 	"github.com/pannagaperumal/moxy/internal/token"
 )
@@ -10,27 +12,49 @@ import (
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN      // =
+	LOGICAL_OR  // ||
+	LOGICAL_AND // &&
 	EQUALS      // ==
 	LESSGREATER // > or <
-	SUM         // +
-	PRODUCT     // *
+	SUM         // +, |, ^
+	PRODUCT     // *, &, <<
 	PREFIX      // -X or !X
 	CALL        // myFunction(X)
 	INDEX       // array[index]
 )
 
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
-	token.ASSIGN:   EQUALS, // Use EQUALS precedence for now
-	token.LBRACKET: INDEX,
+	token.OR:        LOGICAL_OR,
+	token.AND:       LOGICAL_AND,
+	token.EQ:        EQUALS,
+	token.NOT_EQ:    EQUALS,
+	token.LT:        LESSGREATER,
+	token.GT:        LESSGREATER,
+	token.LT_EQ:     LESSGREATER,
+	token.GT_EQ:     LESSGREATER,
+	token.PLUS:      SUM,
+	token.MINUS:     SUM,
+	token.PIPE:      SUM,
+	token.CARET:     SUM,
+	token.SLASH:     PRODUCT,
+	token.ASTERISK:  PRODUCT,
+	token.PERCENT:   PRODUCT,
+	token.AMPERSAND: PRODUCT,
+	token.AND_NOT:   PRODUCT,
+	token.SHL:       PRODUCT,
+	token.SHR:       PRODUCT,
+	token.LPAREN:    CALL,
+	token.ASSIGN:    ASSIGN,
+	token.LBRACKET:  INDEX,
+	token.DOT:       INDEX,
+}
+
+// branchTarget is a loop or switch that break statements, and for a loop
+// continue statements, can apply to.
+type branchTarget struct {
+	label string // "" if unlabeled
+	loop  bool
 }
 
 type (
@@ -40,7 +64,22 @@ type (
 
 type Parser struct {
 	l      *lexer.Lexer
-	errors []string
+	errors []*diag.Diagnostic
+
+	// failed is set by the first error in a statement. Further errors are
+	// dropped until synchronize skips to the next statement, since they
+	// are almost always fallout from the first.
+	failed bool
+
+	// targets holds the loops and switches enclosing the statement being
+	// parsed, innermost last. label is the label waiting for the loop or
+	// switch it names.
+	targets []branchTarget
+	label   string
+
+	// typeSwitch is set while parsing the header of a switch, the one
+	// place x.(type) may appear, and cleared once it has.
+	typeSwitch bool
 
 	curToken  token.Token
 	peekToken token.Token
@@ -52,7 +91,7 @@ type Parser struct {
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
 		l:      l,
-		errors: []string{},
+		errors: []*diag.Diagnostic{},
 	}
 
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
@@ -78,8 +117,20 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.LT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.GT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.AMPERSAND, p.parseInfixExpression)
+	p.registerInfix(token.PIPE, p.parseInfixExpression)
+	p.registerInfix(token.CARET, p.parseInfixExpression)
+	p.registerInfix(token.AND_NOT, p.parseInfixExpression)
+	p.registerInfix(token.SHL, p.parseInfixExpression)
+	p.registerInfix(token.SHR, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.DOT, p.parseSelectorExpression)
 	p.registerInfix(token.ASSIGN, p.parseInfixExpression)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
@@ -114,14 +165,39 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 	}
 }
 
+// Errors returns the parse errors, each prefixed with its position.
 func (p *Parser) Errors() []string {
+	msgs := make([]string, len(p.errors))
+	for i, d := range p.errors {
+		msgs[i] = d.Error()
+	}
+	return msgs
+}
+
+// Diagnostics returns the parse errors with their positions.
+func (p *Parser) Diagnostics() []*diag.Diagnostic {
 	return p.errors
 }
 
+// errorf records an error at pos unless the current statement has already
+// failed or the same error was reported before.
+func (p *Parser) errorf(pos token.Position, format string, a ...any) {
+	if p.failed {
+		return
+	}
+	p.failed = true
+
+	d := diag.Errorf(pos, format, a...)
+	for _, prev := range p.errors {
+		if *prev == *d {
+			return
+		}
+	}
+	p.errors = append(p.errors, d)
+}
+
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead",
-		t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.errorf(p.peekToken.Pos, "expected %s, got %s", t.Describe(), p.peekToken.Describe())
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -130,7 +206,9 @@ func (p *Parser) ParseProgram() *ast.Program {
 
 	for p.curToken.Type != token.EOF {
 		stmt := p.parseStatement()
-		if stmt != nil {
+		if p.failed {
+			p.synchronize()
+		} else if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
 		p.nextToken()
@@ -139,6 +217,75 @@ func (p *Parser) ParseProgram() *ast.Program {
 	return program
 }
 
+// synchronize recovers from an error in the current statement. It skips
+// tokens, stepping over balanced braces, up to the last token of the
+// statement: a semicolon, or the token before a closing brace, the end of
+// input or a keyword that starts a statement. It returns true if it stopped
+// on an unmatched closing brace instead, which the caller must not skip.
+func (p *Parser) synchronize() bool {
+	p.failed = false
+
+	depth := 0
+	for {
+		switch p.curToken.Type {
+		case token.EOF:
+			return false
+		case token.LBRACE:
+			depth++
+		case token.RBRACE:
+			if depth == 0 {
+				return true
+			}
+			depth--
+		case token.SEMICOLON:
+			if depth == 0 {
+				return false
+			}
+		}
+
+		if depth == 0 && (p.peekTokenIs(token.RBRACE) || p.peekTokenIs(token.EOF) ||
+			startsStatement(p.peekToken.Type)) {
+			return false
+		}
+		p.nextToken()
+	}
+}
+
+// startsStatement reports whether t can only begin a statement or a switch
+// case.
+func startsStatement(t token.TokenType) bool {
+	switch t {
+	case token.VAR, token.LET, token.RETURN, token.FOR, token.WHILE, token.BREAK, token.CONTINUE,
+		token.SWITCH, token.CASE, token.DEFAULT, token.FALLTHROUGH:
+		return true
+	}
+	return false
+}
+
+// enterTarget records that the body of a loop, or a switch if loop is
+// false, is being parsed, taking the pending label if there is one. Each
+// call is paired with leaveTarget.
+func (p *Parser) enterTarget(loop bool) {
+	p.targets = append(p.targets, branchTarget{label: p.label, loop: loop})
+	p.label = ""
+}
+
+func (p *Parser) leaveTarget() {
+	p.targets = p.targets[:len(p.targets)-1]
+}
+
+// hasTarget reports whether a break, or a continue if loop is set, naming
+// label, or the innermost target if label is "", has a loop or switch to
+// apply to. A continue skips switches.
+func (p *Parser) hasTarget(label string, loop bool) bool {
+	for _, t := range slices.Backward(p.targets) {
+		if (label == "" || t.label == label) && (t.loop || !loop) {
+			return true
+		}
+	}
+	return false
+}
+
 func (p *Parser) peekPrecedence() int {
 	if p, ok := precedences[p.peekToken.Type]; ok {
 		return p
@@ -161,7 +308,6 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 	p.infixParseFns[tokenType] = fn
 }
 
-func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+func (p *Parser) noPrefixParseFnError(tok token.Token) {
+	p.errorf(tok.Pos, "unexpected %s", tok.Describe())
 }
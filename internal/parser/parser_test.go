@@ -0,0 +1,156 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pannagaperumal/moxy/ast"
+	"github.com/pannagaperumal/moxy/internal/lexer"
+)
+
+func TestSelectorExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a.b", "(a.b)"},
+		{"a.b.c", "((a.b).c)"},
+		{"a.b[0]", "((a.b)[0])"},
+		{"a[0].b", "((a[0]).b)"},
+		{"a.b(1)", "(a.b)(1)"},
+		{"-a.b", "(-(a.b))"},
+		{"a.b = 1", "((a.b) = 1)"},
+		{"a.item2 + 5.5", "((a.item2) + 5.5)"},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		if got := program.String(); got != tt.expected {
+			t.Fatalf("%q - expected=%s, got=%s", tt.input, tt.expected, got)
+		}
+	}
+
+	stmt := parse(t, "order.total").Statements[0].(*ast.ExpressionStatement)
+	selector, ok := stmt.Expression.(*ast.SelectorExpression)
+	if !ok {
+		t.Fatalf("expected *ast.SelectorExpression, got=%T", stmt.Expression)
+	}
+	if selector.Left.String() != "order" || selector.Property.Value != "total" {
+		t.Fatalf("expected order and total, got=%s and %s", selector.Left, selector.Property.Value)
+	}
+}
+
+func TestSelectorErrors(t *testing.T) {
+	for _, input := range []string{"a.", "a.1", "a.if"} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 || !strings.Contains(p.Errors()[0], "expected identifier, got") {
+			t.Fatalf("%q - expected an identifier error, got=%v", input, p.Errors())
+		}
+	}
+}
+
+func TestOperatorPrecedence(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a || b && c", "(a || (b && c))"},
+		{"a && b == c", "(a && (b == c))"},
+		{"a <= b == c >= d", "((a <= b) == (c >= d))"},
+		{"a < b + c", "(a < (b + c))"},
+		{"a | b & c", "(a | (b & c))"},
+		{"a ^ b << c", "(a ^ (b << c))"},
+		{"a + b % c", "(a + (b % c))"},
+		{"a &^ b >> c * d", "(((a &^ b) >> c) * d)"},
+		{"a = b == c", "(a = (b == c))"},
+		{"a = b || c", "(a = (b || c))"},
+		{"!a && -b | c", "((!a) && ((-b) | c))"},
+	}
+
+	for _, tt := range tests {
+		if got := parse(t, tt.input).String(); got != tt.expected {
+			t.Fatalf("%q - expected=%s, got=%s", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestBranchStatements(t *testing.T) {
+	valid := map[string]string{
+		"for true { break }":                      "for true break;",
+		"for true { if (true) { continue } }":     "for true iftrue continue;",
+		"a: for true { for true { continue a } }": "a: for true for true continue a;",
+		"l: while (true) { break l }":             "l: whiletrue break l;",
+	}
+	for input, expected := range valid {
+		if got := parse(t, input).String(); got != expected {
+			t.Fatalf("%q - expected=%q, got=%q", input, expected, got)
+		}
+	}
+
+	invalid := map[string]string{
+		"break":                           "1:1: break is not in a loop or switch",
+		"continue;":                       "1:1: continue is not in a loop",
+		"for true { func() { break } }":   "1:21: break is not in a loop or switch",
+		"outer: for true { break inner }": "1:25: invalid break label inner",
+		"a: for true { a: for true { break a } }": "1:15: label a already defined",
+		"x: var y = 1": `1:4: expected for, while or switch after label x, got "var"`,
+	}
+	for input, expected := range invalid {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if errs := p.Errors(); len(errs) != 1 || errs[0] != expected {
+			t.Fatalf("%q - expected %q, got=%q", input, expected, errs)
+		}
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input   string
+		errors  []string
+		program string // what parsed, with the failed statements left out
+	}{
+		{"var a = ;\nvar b = 2;\nb", []string{`1:9: unexpected ";"`}, "var b = 2;b"},
+		{"var = 1\nvar c = 3", []string{`1:5: expected identifier, got "="`}, "var c = 3;"},
+		{"let x 5; let y = 10;", []string{`1:7: expected "=", got integer "5"`}, "let y = 10;"},
+		{"var a = (1 + 2;\nvar b = 3;", []string{`1:15: expected ")", got ";"`}, "var b = 3;"},
+		{"var a = 1; var b = ; var c = ; d", []string{`1:20: unexpected ";"`, `1:30: unexpected ";"`}, "var a = 1;d"},
+		// Recovery inside a block stays in the block.
+		{"func f() {\n  var x = ;\n  x\n}\nvar y = 1", []string{`2:11: unexpected ";"`}, "func f() xvar y = 1;"},
+		{"func f() {\n  var x = {1: };\n  x\n}\nf(", []string{`2:15: unexpected "}"`, "5:3: unexpected end of input"}, "func f() x"},
+		// Braces in the skipped tokens are stepped over.
+		{"if (x { 1 } var z = 2", []string{`1:7: expected ")", got "{"`}, "var z = 2;"},
+		{"}", []string{`1:1: unexpected "}"`}, ""},
+		// A bad statement in a case body is dropped from the case; a bad case
+		// drops the whole switch.
+		{"switch x { case 1: var a = ; case 2: if (true) { 1 } }\nvar y = 1", []string{`1:28: unexpected ";"`}, "switch x { case 1:  case 2: iftrue 1 }var y = 1;"},
+		{"switch x { case (1: if (true) { 1 } case 2: 2 }\nvar y = 1", []string{`1:19: expected ")", got ":"`}, "var y = 1;"},
+		{"switch x { case : 1 }\ny", []string{`1:17: unexpected ":"`}, "y"},
+		{"switch x { default: 1 default: 2 }\ny", []string{"1:23: multiple defaults in switch"}, "y"},
+	}
+
+	for i, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+
+		errs := p.Errors()
+		if strings.Join(errs, "\n") != strings.Join(tt.errors, "\n") {
+			t.Fatalf("tests[%d] - errors wrong.\nexpected=%q\ngot=%q", i, tt.errors, errs)
+		}
+		if program.String() != tt.program {
+			t.Fatalf("tests[%d] - program wrong. expected=%q, got=%q", i, tt.program, program.String())
+		}
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return program
+}
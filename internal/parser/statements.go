@@ -13,6 +13,13 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseReturnStatement()
 	case token.FOR:
 		return p.parseForStatement()
+	case token.BREAK, token.CONTINUE:
+		return p.parseBranchStatement()
+	case token.SWITCH:
+		return p.parseSwitchStatement()
+	case token.FALLTHROUGH:
+		p.errorf(p.curToken.Pos, "fallthrough statement out of place")
+		return nil
 	case token.FUNCTION:
 		if p.peekTokenIs(token.IDENT) {
 			return p.parseNamedFunctionStatement()
@@ -23,19 +30,27 @@ func (p *Parser) parseStatement() ast.Statement {
 		if p.curToken.Type == token.IDENT && p.peekToken.Type == token.DECLARE_ASSIGN {
 			return p.parseShortDeclareStatement()
 		}
+		if p.curToken.Type == token.IDENT && p.peekToken.Type == token.COLON {
+			return p.parseLabeledStatement()
+		}
 		return p.parseExpressionStatement()
 	}
 }
 
 func (p *Parser) parseShortDeclareStatement() *ast.VarStatement {
-	stmt := &ast.VarStatement{Token: p.curToken} // We reuse VarStatement
-	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-
+	name := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 	p.nextToken() // move to :=
-	stmt.Token = p.curToken
+	return p.parseShortDeclareValue(name)
+}
+
+// parseShortDeclareValue parses the rest of name := value, with := as the
+// current token.
+func (p *Parser) parseShortDeclareValue(name *ast.Identifier) *ast.VarStatement {
+	stmt := &ast.VarStatement{Token: p.curToken, Name: name} // We reuse VarStatement
 
 	p.nextToken() // move to expression
 	stmt.Value = p.parseExpression(LOWEST)
+	nameFunction(stmt)
 
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
@@ -60,6 +75,7 @@ func (p *Parser) parseVarStatement() *ast.VarStatement {
 	p.nextToken()
 
 	stmt.Value = p.parseExpression(LOWEST)
+	nameFunction(stmt)
 
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
@@ -102,7 +118,11 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
 		stmt := p.parseStatement()
-		if stmt != nil {
+		if p.failed {
+			if p.synchronize() {
+				break
+			}
+		} else if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
 		p.nextToken()
@@ -111,19 +131,100 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	return block
 }
 
-func (p *Parser) parseForStatement() *ast.ForStatement {
+// parseLabeledStatement parses a label and the for, while or switch
+// statement it names.
+func (p *Parser) parseLabeledStatement() ast.Statement {
+	stmt := &ast.LabeledStatement{Token: p.curToken}
+	stmt.Label = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.hasTarget(stmt.Label.Value, false) {
+		p.errorf(stmt.Token.Pos, "label %s already defined", stmt.Label.Value)
+		return nil
+	}
+
+	p.nextToken() // move to ':'
+	if !p.peekTokenIs(token.FOR) && !p.peekTokenIs(token.WHILE) && !p.peekTokenIs(token.SWITCH) {
+		p.errorf(p.peekToken.Pos, "expected for, while or switch after label %s, got %s",
+			stmt.Label.Value, p.peekToken.Describe())
+		return nil
+	}
+	p.nextToken()
+
+	p.label = stmt.Label.Value
+	stmt.Statement = p.parseStatement()
+	return stmt
+}
+
+// parseBranchStatement parses break or continue with an optional label. A
+// break must be inside a loop or switch of the same function, and a continue
+// inside a loop. A label must name one of them.
+func (p *Parser) parseBranchStatement() ast.Statement {
+	stmt := &ast.BranchStatement{Token: p.curToken}
+
+	if p.peekTokenIs(token.IDENT) {
+		p.nextToken()
+		stmt.Label = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
+	loop := stmt.Token.Type == token.CONTINUE
+	switch {
+	case stmt.Label == nil && !p.hasTarget("", loop):
+		if loop {
+			p.errorf(stmt.Token.Pos, "%s is not in a loop", stmt.Token.Literal)
+		} else {
+			p.errorf(stmt.Token.Pos, "%s is not in a loop or switch", stmt.Token.Literal)
+		}
+		return nil
+	case stmt.Label != nil && !p.hasTarget(stmt.Label.Value, loop):
+		p.errorf(stmt.Label.Pos(), "invalid %s label %s", stmt.Token.Literal, stmt.Label.Value)
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseForStatement() ast.Statement {
 	stmt := &ast.ForStatement{Token: p.curToken}
+	p.enterTarget(true)
+	defer p.leaveTarget()
 
 	if p.peekTokenIs(token.LBRACE) {
 		p.nextToken()
 		stmt.Body = p.parseBlockStatement()
+		if p.peekTokenIs(token.SEMICOLON) {
+			p.nextToken()
+		}
 		return stmt
 	}
 
 	p.nextToken()
 
-	// Parse the first part. It could be an init statement or just a condition.
-	firstPart := p.parseStatement()
+	switch {
+	case p.curTokenIs(token.RANGE):
+		return p.parseRangeStatement(stmt.Token, nil)
+	case p.curTokenIs(token.IDENT) && p.peekTokenIs(token.COMMA):
+		key := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		p.nextToken()
+		return p.parseRangeStatement(stmt.Token, key)
+	}
+
+	// Parse the first part. It could be an init statement, a condition or
+	// the variable of a range loop.
+	var firstPart ast.Statement
+	if p.curTokenIs(token.IDENT) && p.peekTokenIs(token.DECLARE_ASSIGN) {
+		name := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		p.nextToken() // move to :=
+		if p.peekTokenIs(token.RANGE) {
+			return p.parseRangeStatement(stmt.Token, name)
+		}
+		firstPart = p.parseShortDeclareValue(name)
+	} else {
+		firstPart = p.parseStatement()
+	}
 
 	if p.curTokenIs(token.SEMICOLON) {
 		// It's 'for init; ...'
@@ -149,27 +250,242 @@ func (p *Parser) parseForStatement() *ast.ForStatement {
 		if es, ok := firstPart.(*ast.ExpressionStatement); ok {
 			stmt.Condition = es.Expression
 		} else {
-			p.errors = append(p.errors, "expected condition in for loop")
+			p.errorf(stmt.Token.Pos, "expected condition in for loop")
+		}
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseRangeStatement parses the rest of a range loop after its first
+// variable, key, which is nil in "for range x". The current token is the
+// ',' or ':=' after key, or 'range'.
+func (p *Parser) parseRangeStatement(forToken token.Token, key *ast.Identifier) ast.Statement {
+	stmt := &ast.RangeStatement{Token: forToken, Key: key}
+
+	if p.curTokenIs(token.COMMA) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
 		}
+		stmt.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+		if !p.expectPeek(token.DECLARE_ASSIGN) {
+			return nil
+		}
+	}
+	if p.curTokenIs(token.DECLARE_ASSIGN) && !p.expectPeek(token.RANGE) {
+		return nil
 	}
 
+	p.nextToken() // move past 'range'
+	stmt.Iterable = p.parseExpression(LOWEST)
+
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
 
 	stmt.Body = p.parseBlockStatement()
 
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
 	return stmt
 }
 
+// parseSwitchStatement parses a switch on a value, a switch with no tag whose
+// cases are conditions, or a type switch: switch v := x.(type) { ... }.
+func (p *Parser) parseSwitchStatement() ast.Statement {
+	stmt := &ast.SwitchStatement{Token: p.curToken}
+	p.enterTarget(false)
+	defer p.leaveTarget()
+
+	if !p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		if p.curTokenIs(token.IDENT) && p.peekTokenIs(token.DECLARE_ASSIGN) {
+			stmt.Binding = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			p.nextToken()
+			p.nextToken()
+		}
+
+		p.typeSwitch = true
+		stmt.Tag = p.parseExpression(LOWEST)
+		guarded := !p.typeSwitch
+		p.typeSwitch = false
+
+		switch {
+		case p.failed:
+			return nil
+		case guarded && !stmt.IsTypeSwitch():
+			p.errorf(stmt.Tag.Pos(), "use of .(type) outside type switch")
+			return nil
+		case stmt.Binding != nil && !stmt.IsTypeSwitch():
+			p.errorf(stmt.Binding.Pos(), "expected x.(type) after %s :=", stmt.Binding.Value)
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	p.nextToken()
+
+	var hasDefault bool
+	for !p.curTokenIs(token.RBRACE) {
+		clause := p.parseCaseClause(stmt.IsTypeSwitch())
+		if clause != nil && clause.Values == nil {
+			if hasDefault {
+				p.errorf(clause.Pos(), "multiple defaults in switch")
+			}
+			hasDefault = true
+		}
+		if p.failed {
+			p.skipSwitchBody()
+			return nil
+		}
+		stmt.Cases = append(stmt.Cases, clause)
+	}
+
+	if n := len(stmt.Cases); n > 0 && stmt.Cases[n-1].Fallthrough {
+		p.errorf(stmt.Cases[n-1].Pos(), "cannot fallthrough final case in switch")
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// skipSwitchBody recovers from an error in a case clause by skipping to the
+// closing brace of the switch. The error has been reported, so the statement
+// is simply dropped, and the statements around the switch parse as usual.
+func (p *Parser) skipSwitchBody() {
+	depth := 0
+	for !p.curTokenIs(token.EOF) {
+		if p.curTokenIs(token.LBRACE) {
+			depth++
+		} else if p.curTokenIs(token.RBRACE) {
+			if depth == 0 {
+				break
+			}
+			depth--
+		}
+		p.nextToken()
+	}
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	p.failed = false
+}
+
+// parseCaseClause parses a case or default and the statements after it, up
+// to the next case, default or the closing brace of the switch, which it
+// leaves as the current token. The values of a type switch case are type
+// names.
+func (p *Parser) parseCaseClause(typeSwitch bool) *ast.CaseClause {
+	clause := &ast.CaseClause{Token: p.curToken}
+
+	switch p.curToken.Type {
+	case token.CASE:
+		for {
+			p.nextToken()
+			var value ast.Expression
+			if typeSwitch {
+				value = p.parseTypeName()
+			} else {
+				value = p.parseExpression(LOWEST)
+			}
+			if p.failed {
+				return nil
+			}
+			clause.Values = append(clause.Values, value)
+
+			if !p.peekTokenIs(token.COMMA) {
+				break
+			}
+			p.nextToken()
+		}
+	case token.DEFAULT:
+	default:
+		p.errorf(p.curToken.Pos, "expected case or default, got %s", p.curToken.Describe())
+		return nil
+	}
+
+	if !p.expectPeek(token.COLON) {
+		return nil
+	}
+	p.nextToken()
+
+	clause.Body = &ast.BlockStatement{Token: clause.Token, Statements: []ast.Statement{}}
+	for !p.curTokenIs(token.CASE) && !p.curTokenIs(token.DEFAULT) && !p.curTokenIs(token.RBRACE) {
+		if p.curTokenIs(token.EOF) {
+			p.errorf(p.curToken.Pos, "expected %s, got %s", token.TokenType(token.RBRACE).Describe(), p.curToken.Describe())
+			return nil
+		}
+
+		if p.curTokenIs(token.FALLTHROUGH) {
+			tok := p.curToken
+			if p.peekTokenIs(token.SEMICOLON) {
+				p.nextToken()
+			}
+			p.nextToken()
+			if !p.curTokenIs(token.CASE) && !p.curTokenIs(token.DEFAULT) && !p.curTokenIs(token.RBRACE) {
+				p.errorf(tok.Pos, "fallthrough statement out of place")
+				return nil
+			}
+			if typeSwitch {
+				p.errorf(tok.Pos, "cannot fallthrough in type switch")
+				return nil
+			}
+			clause.Fallthrough = true
+			break
+		}
+
+		stmt := p.parseStatement()
+		if p.failed {
+			if p.synchronize() {
+				break
+			}
+		} else if stmt != nil {
+			clause.Body.Statements = append(clause.Body.Statements, stmt)
+		}
+		p.nextToken()
+	}
+
+	return clause
+}
+
+// parseTypeName parses a type name in a type switch case, such as int or
+// func.
+func (p *Parser) parseTypeName() ast.Expression {
+	if !p.curTokenIs(token.IDENT) && !p.curTokenIs(token.FUNCTION) {
+		p.errorf(p.curToken.Pos, "expected type name, got %s", p.curToken.Describe())
+		return nil
+	}
+	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+}
+
 func (p *Parser) parseNamedFunctionStatement() ast.Statement {
 	// Current token is 'func' or 'fn'
+	stmt := &ast.VarStatement{Token: p.curToken}
 	p.nextToken() // move to identifier
 
-	stmt := &ast.VarStatement{Token: token.Token{Type: token.LET, Literal: "let"}}
 	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 
 	if !p.peekTokenIs(token.LPAREN) {
+		p.peekError(token.LPAREN)
 		return nil
 	}
 
@@ -177,15 +493,27 @@ func (p *Parser) parseNamedFunctionStatement() ast.Statement {
 	// Actually, let's just parse the FunctionLiteral manually or reuse it.
 	
 	p.nextToken() // move to '('
-	function := &ast.FunctionLiteral{Token: token.Token{Type: token.FUNCTION, Literal: "func"}}
+	function := &ast.FunctionLiteral{Token: stmt.Token, Name: stmt.Name.Value}
 	function.Parameters = p.parseFunctionParameters()
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
 
-	function.Body = p.parseBlockStatement()
+	function.Body = p.parseFunctionBody()
 	stmt.Value = function
 
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
 	return stmt
 }
+
+// nameFunction gives a function literal bound by a var statement the
+// variable's name, so the function can refer to itself.
+func nameFunction(stmt *ast.VarStatement) {
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		fl.Name = stmt.Name.Value
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
+	"github.com/pannagaperumal/moxy/internal/diag"
 	"github.com/pannagaperumal/moxy/internal/evaluator"
 	"github.com/pannagaperumal/moxy/internal/lexer"
 	"github.com/pannagaperumal/moxy/internal/parser"
@@ -32,7 +34,7 @@ func Start(in io.Reader, out io.Writer) {
 
 		program := p.ParseProgram()
 		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+			printParserErrors(out, line, p.Diagnostics())
 			continue
 		}
 
@@ -44,11 +46,12 @@ func Start(in io.Reader, out io.Writer) {
 	}
 }
 
-func printParserErrors(out io.Writer, errors []string) {
+func printParserErrors(out io.Writer, src string, errors []*diag.Diagnostic) {
 	io.WriteString(out, "Woops! We ran into some monkey business here!\n")
 	io.WriteString(out, " parser errors:\n")
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+	for _, d := range errors {
+		msg := diag.Format(src, d.Pos, d.Message)
+		io.WriteString(out, "\t"+strings.ReplaceAll(msg, "\n", "\n\t")+"\n")
 	}
 }
 
@@ -59,11 +62,11 @@ func RunFile(filename string, out io.Writer) {
 		return
 	}
 
-	l := lexer.New(string(content))
+	l := lexer.NewFile(filename, string(content))
 	p := parser.New(l)
 	program := p.ParseProgram()
 	if len(p.Errors()) != 0 {
-		printParserErrors(out, p.Errors())
+		printParserErrors(out, string(content), p.Diagnostics())
 		return
 	}
 
@@ -74,8 +77,8 @@ func RunFile(filename string, out io.Writer) {
 	evaluated := evaluator.Eval(program, env)
 
 	// Check for evaluation errors
-	if evaluated != nil && evaluated.Type() == types.ERROR_OBJ {
-		fmt.Fprintf(out, "Runtime error: %s\n", evaluated.Inspect())
+	if errObj, ok := evaluated.(*types.Error); ok {
+		fmt.Fprintf(out, "Runtime error: %s\n", diag.Format(string(content), errObj.Pos, errObj.Inspect()))
 	}
 
 	// Note: We don't print the result of the last expression for script files
@@ -20,6 +20,11 @@ type SymbolTable struct {
 	store          map[string]Symbol
 	numDefinitions int
 	FreeSymbols    []Symbol
+
+	// block marks a table for a block inside a function or the top level.
+	// Its names are its own, but their slots belong to the enclosing
+	// table, so they stay globals or locals of the same function.
+	block bool
 }
 
 func NewSymbolTable() *SymbolTable {
@@ -34,22 +39,59 @@ func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
 	return s
 }
 
+// NewBlockSymbolTable returns a table for a block nested in outer. Names
+// defined in it are only visible inside the block.
+func NewBlockSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewEnclosedSymbolTable(outer)
+	s.block = true
+	return s
+}
+
 func (s *SymbolTable) Define(name string) Symbol {
-	symbol := Symbol{Name: name, Index: s.numDefinitions}
+	symbol := s.allocate()
+	symbol.Name = name
+	s.store[name] = symbol
+	return symbol
+}
+
+// allocate reserves the next global or local slot of the function or top
+// level that s belongs to.
+func (s *SymbolTable) allocate() Symbol {
+	if s.block {
+		return s.Outer.allocate()
+	}
 
+	symbol := Symbol{Index: s.numDefinitions}
 	if s.Outer == nil {
 		symbol.Scope = GlobalScope
 	} else {
 		symbol.Scope = LocalScope
 	}
-
-	s.store[name] = symbol
 	s.numDefinitions++
 	return symbol
 }
 
+// DefineGlobal defines name in the outermost table, whatever the current
+// scope.
+func (s *SymbolTable) DefineGlobal(name string) Symbol {
+	if s.Outer != nil {
+		return s.Outer.DefineGlobal(name)
+	}
+	return s.Define(name)
+}
+
+// DefinedHere reports whether name is a global or local defined in s itself,
+// not in an enclosing table.
+func (s *SymbolTable) DefinedHere(name string) bool {
+	sym, ok := s.store[name]
+	return ok && (sym.Scope == GlobalScope || sym.Scope == LocalScope)
+}
+
 func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
 	obj, ok := s.store[name]
+	if !ok && s.block {
+		return s.Outer.Resolve(name)
+	}
 	if !ok && s.Outer != nil {
 		obj, ok = s.Outer.Resolve(name)
 		if !ok {
@@ -1,10 +1,43 @@
 package token
 
+import "fmt"
+
 type TokenType string
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position // where the token starts
+}
+
+// Position is a location in a source file. Line and Column are 1-based;
+// Column counts characters (runes), so it matches what an editor shows.
+// The zero Position is unknown.
+type Position struct {
+	File   string
+	Line   int
+	Column int
+	Offset int // byte offset from the start of the file
+}
+
+// IsValid reports whether the position is known.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String returns "file:line:column", leaving out the file name when there
+// is none.
+func (p Position) String() string {
+	if !p.IsValid() {
+		if p.File != "" {
+			return p.File
+		}
+		return "-"
+	}
+	if p.File == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
 }
 
 const (
@@ -26,18 +59,32 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
 
 	LT     = "<"
 	GT     = ">"
+	LT_EQ  = "<="
+	GT_EQ  = ">="
 	EQ     = "=="
 	NOT_EQ = "!="
 
+	AND = "&&"
+	OR  = "||"
+
+	AMPERSAND = "&"
+	PIPE      = "|"
+	CARET     = "^"
+	AND_NOT   = "&^"
+	SHL       = "<<"
+	SHR       = ">>"
+
 	COLON          = ":"
 	DECLARE_ASSIGN = ":="
 
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
+	DOT       = "."
 
 	LPAREN = "("
 	RPAREN = ")"
@@ -48,29 +95,106 @@ const (
 	RBRACKET = "]"
 
 	// Keywords
-	FUNCTION = "FUNCTION"
-	VAR      = "VAR"
-	TRUE     = "TRUE"
-	FALSE    = "FALSE"
-	IF       = "IF"
-	ELSE     = "ELSE"
-	RETURN   = "RETURN"
-	WHILE    = "WHILE"
-	FOR      = "FOR"
+	FUNCTION    = "FUNCTION"
+	VAR         = "VAR"
+	TRUE        = "TRUE"
+	FALSE       = "FALSE"
+	IF          = "IF"
+	ELSE        = "ELSE"
+	RETURN      = "RETURN"
+	WHILE       = "WHILE"
+	FOR         = "FOR"
+	BREAK       = "BREAK"
+	CONTINUE    = "CONTINUE"
+	RANGE       = "RANGE"
+	SWITCH      = "SWITCH"
+	CASE        = "CASE"
+	DEFAULT     = "DEFAULT"
+	FALLTHROUGH = "FALLTHROUGH"
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"var":    VAR,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
-	"while":  WHILE,
-	"for":    FOR,
-	"let":    LET,
-	"func":   FUNCTION,
+	"fn":          FUNCTION,
+	"var":         VAR,
+	"true":        TRUE,
+	"false":       FALSE,
+	"if":          IF,
+	"else":        ELSE,
+	"return":      RETURN,
+	"while":       WHILE,
+	"for":         FOR,
+	"break":       BREAK,
+	"continue":    CONTINUE,
+	"range":       RANGE,
+	"switch":      SWITCH,
+	"case":        CASE,
+	"default":     DEFAULT,
+	"fallthrough": FALLTHROUGH,
+	"let":         LET,
+	"func":        FUNCTION,
+}
+
+// names are the readable names of token types that are not shown as their
+// own text in error messages.
+var names = map[TokenType]string{
+	ILLEGAL:     "illegal character",
+	EOF:         "end of input",
+	IDENT:       "identifier",
+	INT:         "integer",
+	FLOAT:       "float",
+	STRING:      "string",
+	FUNCTION:    "func",
+	FUNC:        "func",
+	VAR:         "var",
+	LET:         "let",
+	TRUE:        "true",
+	FALSE:       "false",
+	IF:          "if",
+	ELSE:        "else",
+	RETURN:      "return",
+	WHILE:       "while",
+	FOR:         "for",
+	BREAK:       "break",
+	CONTINUE:    "continue",
+	RANGE:       "range",
+	SWITCH:      "switch",
+	CASE:        "case",
+	DEFAULT:     "default",
+	FALLTHROUGH: "fallthrough",
+}
+
+// Describe returns a readable name for t for use in error messages, e.g.
+// "identifier", "end of input" or ")" quoted.
+func (t TokenType) Describe() string {
+	name, ok := names[t]
+	if !ok {
+		name = string(t)
+	}
+	if t.isWord() {
+		return name
+	}
+	return fmt.Sprintf("%q", name)
+}
+
+// isWord reports whether t stands for a class of tokens rather than one
+// spelling.
+func (t TokenType) isWord() bool {
+	switch t {
+	case ILLEGAL, EOF, IDENT, INT, FLOAT, STRING:
+		return true
+	}
+	return false
+}
+
+// Describe returns a readable description of tok for use in error messages,
+// including its text when the type alone does not say what it was, e.g.
+// identifier "total".
+func (tok Token) Describe() string {
+	switch tok.Type {
+	case ILLEGAL, IDENT, INT, FLOAT, STRING:
+		return fmt.Sprintf("%s %q", tok.Type.Describe(), tok.Literal)
+	}
+	return tok.Type.Describe()
 }
 
 func LookupIdent(ident string) TokenType {
@@ -0,0 +1,14 @@
+package vm
+
+import "github.com/pannagaperumal/moxy/internal/limits"
+
+// Limits bounds the resources a run may use. It is shared with the
+// evaluator, which accepts the same limits.
+type Limits = limits.Limits
+
+var (
+	ErrBudgetExceeded = limits.ErrBudgetExceeded
+	ErrMaxDepth       = limits.ErrMaxDepth
+	ErrStackOverflow  = limits.ErrStackOverflow
+	ErrMemoryLimit    = limits.ErrMemoryLimit
+)
@@ -2,12 +2,14 @@ package vm
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 
+	"github.com/pannagaperumal/moxy/internal/code"
 	"github.com/pannagaperumal/moxy/types"
 )
 
-func (vm *VM) executeBinaryOperation(op Opcode) error {
+func (vm *VM) executeBinaryOperation(op code.Opcode) error {
 	right := vm.pop()
 	left := vm.pop()
 
@@ -17,76 +19,228 @@ func (vm *VM) executeBinaryOperation(op Opcode) error {
 	switch {
 	case leftType == types.INTEGER_OBJ && rightType == types.INTEGER_OBJ:
 		return vm.executeBinaryIntegerOperation(op, left, right)
+	case leftType == types.FLOAT_OBJ && rightType == types.FLOAT_OBJ:
+		return vm.executeBinaryFloatOperation(op, left.(*types.Float).Value, right.(*types.Float).Value)
+	case leftType == types.FLOAT_OBJ && rightType == types.INTEGER_OBJ,
+		leftType == types.INTEGER_OBJ && rightType == types.FLOAT_OBJ:
+		return vm.executeMixedNumberOperation(op, left, right)
 	case leftType == types.STRING_OBJ && rightType == types.STRING_OBJ:
 		return vm.executeBinaryStringOperation(op, left, right)
+	case leftType == types.HOST_OBJ || rightType == types.HOST_OBJ:
+		result, err := types.HostBinaryOp(binaryOperators[op], left, right)
+		if err != nil {
+			return err
+		}
+		return vm.pushAllocated(result)
+	case op == code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(types.Equal(left, right)))
+	case op == code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(!types.Equal(left, right)))
+	case isOrdering(op):
+		return vm.executeOrdering(op, left, right)
+	case leftType != rightType:
+		return fmt.Errorf("type mismatch: %s %s %s",
+			leftType, binaryOperators[op], rightType)
 	default:
-		return fmt.Errorf("unsupported types for binary operation: %s %s",
-			leftType, rightType)
+		return fmt.Errorf("unknown operator: %s %s %s",
+			leftType, binaryOperators[op], rightType)
 	}
 }
 
-func (vm *VM) executeBinaryIntegerOperation(op Opcode, left, right types.Object) error {
+// binaryOperators maps binary opcodes to their source operators, for host
+// objects that implement types.HostOperator.
+var binaryOperators = map[code.Opcode]string{
+	code.OpAdd:            "+",
+	code.OpSub:            "-",
+	code.OpMul:            "*",
+	code.OpDiv:            "/",
+	code.OpMod:            "%",
+	code.OpEqual:          "==",
+	code.OpNotEqual:       "!=",
+	code.OpGreaterThan:    ">",
+	code.OpLessThan:       "<",
+	code.OpGreaterOrEqual: ">=",
+	code.OpLessOrEqual:    "<=",
+	code.OpBitAnd:         "&",
+	code.OpBitOr:          "|",
+	code.OpBitXor:         "^",
+	code.OpBitAndNot:      "&^",
+	code.OpShiftLeft:      "<<",
+	code.OpShiftRight:     ">>",
+}
+
+func (vm *VM) executeBinaryIntegerOperation(op code.Opcode, left, right types.Object) error {
 	leftVal := left.(*types.Integer).Value
 	rightVal := right.(*types.Integer).Value
 
 	var result int64
 
 	switch op {
-	case OpAdd:
+	case code.OpAdd:
 		result = leftVal + rightVal
-	case OpSub:
+	case code.OpSub:
 		result = leftVal - rightVal
-	case OpMul:
+	case code.OpMul:
 		result = leftVal * rightVal
-	case OpDiv:
+	case code.OpDiv:
 		if rightVal == 0 {
 			return fmt.Errorf("division by zero")
 		}
 		result = leftVal / rightVal
-	case OpMod:
+	case code.OpMod:
 		if rightVal == 0 {
 			return fmt.Errorf("modulo by zero")
 		}
 		result = leftVal % rightVal
-	case OpEqual:
+	case code.OpBitAnd:
+		result = leftVal & rightVal
+	case code.OpBitOr:
+		result = leftVal | rightVal
+	case code.OpBitXor:
+		result = leftVal ^ rightVal
+	case code.OpBitAndNot:
+		result = leftVal &^ rightVal
+	case code.OpShiftLeft:
+		if rightVal < 0 {
+			return fmt.Errorf("negative shift count: %d", rightVal)
+		}
+		result = leftVal << rightVal
+	case code.OpShiftRight:
+		if rightVal < 0 {
+			return fmt.Errorf("negative shift count: %d", rightVal)
+		}
+		result = leftVal >> rightVal
+	case code.OpEqual:
 		return vm.push(nativeBoolToBooleanObject(leftVal == rightVal))
-	case OpNotEqual:
+	case code.OpNotEqual:
 		return vm.push(nativeBoolToBooleanObject(leftVal != rightVal))
-	case OpGreaterThan:
+	case code.OpGreaterThan:
 		return vm.push(nativeBoolToBooleanObject(leftVal > rightVal))
-	case OpLessThan:
+	case code.OpLessThan:
 		return vm.push(nativeBoolToBooleanObject(leftVal < rightVal))
-	case OpGreaterOrEqual:
+	case code.OpGreaterOrEqual:
 		return vm.push(nativeBoolToBooleanObject(leftVal >= rightVal))
-	case OpLessOrEqual:
+	case code.OpLessOrEqual:
 		return vm.push(nativeBoolToBooleanObject(leftVal <= rightVal))
 	default:
-		return fmt.Errorf("unknown integer operator: %d", op)
+		return fmt.Errorf("unknown operator: INTEGER %s INTEGER", binaryOperators[op])
 	}
 
 	return vm.push(&types.Integer{Value: result})
 }
 
-func (vm *VM) executeBinaryStringOperation(op Opcode, left, right types.Object) error {
-	if op != OpAdd {
-		return fmt.Errorf("unknown string operator: %d", op)
+// executeMixedNumberOperation applies op to an integer and a float, as the
+// evaluator does: comparisons are exact and arithmetic promotes the integer
+// to a float.
+func (vm *VM) executeMixedNumberOperation(op code.Opcode, left, right types.Object) error {
+	switch op {
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(types.Equal(left, right)))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(!types.Equal(left, right)))
+	case code.OpGreaterThan, code.OpLessThan, code.OpGreaterOrEqual, code.OpLessOrEqual:
+		return vm.executeOrdering(op, left, right)
+	case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv:
+		return vm.executeBinaryFloatOperation(op, toFloat(left), toFloat(right))
+	default:
+		return fmt.Errorf("unknown operator: %s %s %s",
+			left.Type(), binaryOperators[op], right.Type())
 	}
+}
 
+// toFloat converts a number to a float64.
+func toFloat(obj types.Object) float64 {
+	if i, ok := obj.(*types.Integer); ok {
+		return float64(i.Value)
+	}
+	return obj.(*types.Float).Value
+}
+
+// executeBinaryFloatOperation applies op to two floats.
+func (vm *VM) executeBinaryFloatOperation(op code.Opcode, leftVal, rightVal float64) error {
+	var result float64
+
+	switch op {
+	case code.OpAdd:
+		result = leftVal + rightVal
+	case code.OpSub:
+		result = leftVal - rightVal
+	case code.OpMul:
+		result = leftVal * rightVal
+	case code.OpDiv:
+		result = leftVal / rightVal
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(leftVal == rightVal))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(leftVal != rightVal))
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToBooleanObject(leftVal > rightVal))
+	case code.OpLessThan:
+		return vm.push(nativeBoolToBooleanObject(leftVal < rightVal))
+	case code.OpGreaterOrEqual:
+		return vm.push(nativeBoolToBooleanObject(leftVal >= rightVal))
+	case code.OpLessOrEqual:
+		return vm.push(nativeBoolToBooleanObject(leftVal <= rightVal))
+	default:
+		return fmt.Errorf("unknown operator: FLOAT %s FLOAT", binaryOperators[op])
+	}
+
+	return vm.push(&types.Float{Value: result})
+}
+
+func (vm *VM) executeBinaryStringOperation(op code.Opcode, left, right types.Object) error {
 	leftVal := left.(*types.String).Value
 	rightVal := right.(*types.String).Value
 
-	return vm.push(&types.String{Value: leftVal + rightVal})
+	switch op {
+	case code.OpAdd:
+		return vm.pushAllocated(&types.String{Value: leftVal + rightVal})
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(leftVal == rightVal))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(leftVal != rightVal))
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToBooleanObject(leftVal > rightVal))
+	case code.OpLessThan:
+		return vm.push(nativeBoolToBooleanObject(leftVal < rightVal))
+	case code.OpGreaterOrEqual:
+		return vm.push(nativeBoolToBooleanObject(leftVal >= rightVal))
+	case code.OpLessOrEqual:
+		return vm.push(nativeBoolToBooleanObject(leftVal <= rightVal))
+	default:
+		return fmt.Errorf("unknown operator: STRING %s STRING", binaryOperators[op])
+	}
+}
+
+func isOrdering(op code.Opcode) bool {
+	switch op {
+	case code.OpGreaterThan, code.OpLessThan, code.OpGreaterOrEqual, code.OpLessOrEqual:
+		return true
+	}
+	return false
+}
+
+// executeOrdering orders values of any type through types.Order, so values
+// that cannot be ordered fail with the same error as in the evaluator.
+func (vm *VM) executeOrdering(op code.Opcode, left, right types.Object) error {
+	result, err := types.Order(binaryOperators[op], left, right)
+	if err != nil {
+		return err
+	}
+	return vm.push(nativeBoolToBooleanObject(result))
 }
 
 func (vm *VM) executeMinusOperator() error {
 	operand := vm.pop()
 
-	if operand.Type() != types.INTEGER_OBJ {
+	switch operand := operand.(type) {
+	case *types.Integer:
+		return vm.push(&types.Integer{Value: -operand.Value})
+	case *types.Float:
+		return vm.push(&types.Float{Value: -operand.Value})
+	default:
 		return fmt.Errorf("unsupported type for negation: %s", operand.Type())
 	}
-
-	value := operand.(*types.Integer).Value
-	return vm.push(&types.Integer{Value: -value})
 }
 
 func (vm *VM) executeBangOperator() error {
@@ -108,6 +262,12 @@ func (vm *VM) executeIndexExpression(left, index types.Object) error {
 		return vm.executeArrayIndex(left, index)
 	case left.Type() == types.HASH_OBJ:
 		return vm.executeHashIndex(left, index)
+	case left.Type() == types.HOST_OBJ:
+		result, err := left.(*types.HostObject).Index(index)
+		if err != nil {
+			return err
+		}
+		return vm.push(result)
 	default:
 		return fmt.Errorf("index operator not supported: %s", left.Type())
 	}
@@ -128,17 +288,33 @@ func (vm *VM) executeArrayIndex(array, index types.Object) error {
 func (vm *VM) executeHashIndex(hash, index types.Object) error {
 	hashObject := hash.(*types.Hash)
 
-	key, ok := index.(types.Hashable)
-	if !ok {
+	if _, ok := types.HashKeyOf(index); !ok {
 		return fmt.Errorf("unusable as hash key: %s", index.Type())
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	value, ok := hashObject.Get(index)
 	if !ok {
 		return vm.push(types.NULL)
 	}
 
-	return vm.push(pair.Value)
+	return vm.push(value)
+}
+
+// executeIterNext advances the range loop iterator on top of the stack,
+// pushing the next key and value, or jumps to pos when it is done.
+func (vm *VM) executeIterNext(pos int) error {
+	iter := vm.stack[vm.sp-1].(*types.Iterator)
+
+	key, value, ok := iter.Next()
+	if !ok {
+		vm.currentFrame().ip = pos - 1
+		return nil
+	}
+
+	if err := vm.push(key); err != nil {
+		return err
+	}
+	return vm.push(value)
 }
 
 func (vm *VM) executeCall(numArgs int) error {
@@ -160,8 +336,14 @@ func (vm *VM) callFunction(cl *types.Closure, numArgs int) error {
 	}
 
 	frame := NewFrame(cl, vm.sp-numArgs)
-	vm.pushFrame(frame)
+	if frame.basePointer+cl.Fn.NumLocals > len(vm.stack) {
+		return ErrStackOverflow
+	}
+	if err := vm.pushFrame(frame); err != nil {
+		return err
+	}
 	vm.sp = frame.basePointer + cl.Fn.NumLocals
+	clear(vm.stack[frame.basePointer+numArgs : vm.sp]) // see ErrUninitialized
 
 	return nil
 }
@@ -175,30 +357,25 @@ func (vm *VM) executeArrayLiteral() error {
 		array[i] = vm.pop()
 	}
 
-	return vm.push(&types.Array{Elements: array})
+	return vm.pushAllocated(&types.Array{Elements: array})
 }
 
 func (vm *VM) executeHashLiteral() error {
-	numPairs := int(binary.BigEndian.Uint16(vm.currentFrame().cl.Fn.Instructions[vm.currentFrame().ip+1:]))
+	// The operand counts stack elements: a key and a value per pair.
+	numElements := int(binary.BigEndian.Uint16(vm.currentFrame().cl.Fn.Instructions[vm.currentFrame().ip+1:]))
 	vm.currentFrame().ip += 2
 
-	hash := make(map[types.HashKey]types.HashPair)
-
-	for i := 0; i < numPairs; i++ {
-		value := vm.pop()
-		key := vm.pop()
-
-		pair := types.HashPair{Key: key, Value: value}
-
-		hashKey, ok := key.(types.Hashable)
-		if !ok {
-			return fmt.Errorf("unusable as hash key: %s", key.Type())
+	// The pairs are on the stack in source order, which the hash keeps.
+	elements := vm.stack[vm.sp-numElements : vm.sp]
+	hash := types.NewHash(numElements / 2)
+	for i := 0; i < numElements; i += 2 {
+		if err := hash.Set(elements[i], elements[i+1]); err != nil {
+			return err
 		}
-
-		hash[hashKey.HashKey()] = pair
 	}
+	vm.sp -= numElements
 
-	return vm.push(&types.Hash{Pairs: hash})
+	return vm.pushAllocated(hash)
 }
 
 func (vm *VM) callBuiltin(builtin *types.Builtin, numArgs int) error {
@@ -207,13 +384,19 @@ func (vm *VM) callBuiltin(builtin *types.Builtin, numArgs int) error {
 	result := builtin.Fn(args...)
 	vm.sp = vm.sp - numArgs - 1
 
-	if result != nil {
-		vm.push(result)
-	} else {
-		vm.push(types.NULL)
+	// As in the evaluator, a builtin that returns an error fails the
+	// script, and the host's error stays reachable through errors.As.
+	if errObj, ok := result.(*types.Error); ok {
+		if errObj.Err != nil {
+			return errObj.Err
+		}
+		return errors.New(errObj.Message)
 	}
 
-	return nil
+	if result == nil {
+		return vm.push(types.NULL)
+	}
+	return vm.pushAllocated(result)
 }
 
 func nativeBoolToBooleanObject(input bool) *types.Boolean {
@@ -3,7 +3,7 @@ package vm
 import "github.com/pannagaperumal/moxy/types"
 
 func (vm *VM) push(o types.Object) error {
-	if vm.sp >= StackSize {
+	if vm.sp >= len(vm.stack) {
 		return ErrStackOverflow
 	}
 
@@ -0,0 +1,33 @@
+package vm
+
+import "github.com/pannagaperumal/moxy/types"
+
+// captureUpvalue returns the open upvalue for stack slot, creating it if no
+// closure has captured the slot yet. Closures over the same variable thereby
+// share one upvalue.
+func (vm *VM) captureUpvalue(slot int) *types.Upvalue {
+	for _, uv := range vm.openUpvalues {
+		if uv.Slot == slot {
+			return uv
+		}
+	}
+
+	uv := &types.Upvalue{Location: &vm.stack[slot], Slot: slot}
+	vm.openUpvalues = append(vm.openUpvalues, uv)
+	return uv
+}
+
+// closeUpvalues closes the open upvalues at or above stack slot from. It runs
+// whenever the stack is unwound past captured locals.
+func (vm *VM) closeUpvalues(from int) {
+	open := vm.openUpvalues[:0]
+	for _, uv := range vm.openUpvalues {
+		if uv.Slot >= from {
+			uv.Close()
+		} else {
+			open = append(open, uv)
+		}
+	}
+	clear(vm.openUpvalues[len(open):])
+	vm.openUpvalues = open
+}
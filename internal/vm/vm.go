@@ -1,27 +1,55 @@
 package vm
 
 import (
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
 
 	"github.com/pannagaperumal/moxy/internal/code"
 	"github.com/pannagaperumal/moxy/internal/compiler"
+	"github.com/pannagaperumal/moxy/internal/diag"
+	"github.com/pannagaperumal/moxy/internal/limits"
 	"github.com/pannagaperumal/moxy/types"
 )
 
-const StackSize = 2048
+const StackSize = limits.StackSize
 const GlobalsSize = 65536
-const MaxFrames = 1024
+const MaxFrames = limits.MaxFrames
+
+// interruptCheckInterval is how many instructions run between two polls of
+// the context passed to RunContext.
+const interruptCheckInterval = 1024
 
 var (
-	ErrStackOverflow   = errors.New("stack overflow")
 	ErrUndefinedGlobal = errors.New("undefined global variable")
+	ErrUninitialized   = errors.New("variable used before it was assigned")
 )
 
+// RuntimeError is returned when a script fails while running. Pos is the
+// source position of the instruction that failed, if the compiler recorded
+// one, and Stack lists the script calls that were active, innermost first.
+type RuntimeError struct {
+	Pos   types.Position
+	Err   error
+	Stack []types.StackFrame
+}
+
+func (e *RuntimeError) Error() string {
+	return diag.Prefix(e.Pos, e.Err.Error())
+}
+
+func (e *RuntimeError) Unwrap() error { return e.Err }
+
+// StackTrace formats Stack one frame per line, innermost first.
+func (e *RuntimeError) StackTrace() string {
+	return types.FormatStack(e.Stack)
+}
+
 type VM struct {
 	constants    []types.Object
 	instructions code.Instructions
+	builtins     *types.BuiltinTable
 
 	stack []types.Object
 	sp    int // Always points to the next value. Top of stack is stack[sp-1]
@@ -30,37 +58,119 @@ type VM struct {
 
 	frames     []*Frame
 	frameIndex int
+
+	openUpvalues []*types.Upvalue // captured stack slots whose frames are live
+
+	limits Limits
+	steps  int64
+	memory *limits.Accountant
 }
 
 func New(bytecode *compiler.Bytecode) *VM {
-	mainFn := &types.CompiledFunction{Instructions: bytecode.Instructions}
+	return NewWithLimits(bytecode, Limits{})
+}
+
+// NewWithLimits creates a VM whose runs are bounded by lim.
+func NewWithLimits(bytecode *compiler.Bytecode, lim Limits) *VM {
+	return NewWithGlobals(bytecode, make([]types.Object, GlobalsSize), lim)
+}
+
+// NewWithGlobals creates a VM that reads and writes globals in the given
+// store, which must have a slot for every global the bytecode uses.
+func NewWithGlobals(bytecode *compiler.Bytecode, globals []types.Object, lim Limits) *VM {
+	lim = lim.WithDefaults()
+
+	builtins := bytecode.Builtins
+	if builtins == nil {
+		builtins = types.NewBuiltinTable()
+	}
+
+	mainFn := &types.CompiledFunction{
+		Name:         types.MainFunctionName,
+		Instructions: bytecode.Instructions,
+		Positions:    bytecode.Positions,
+	}
 	mainClosure := &types.Closure{Fn: mainFn}
 	mainFrame := NewFrame(mainClosure, 0)
 
-	frames := make([]*Frame, MaxFrames)
+	frames := make([]*Frame, 1, min(lim.MaxFrames, MaxFrames))
 	frames[0] = mainFrame
 
-	return &VM{
+	vm := &VM{
 		instructions: bytecode.Instructions,
 		constants:    bytecode.Constants,
+		builtins:     builtins,
 
-		stack: make([]types.Object, StackSize),
+		stack: make([]types.Object, lim.MaxStack),
 		sp:    0,
 
-		globals: make([]types.Object, GlobalsSize),
+		globals: globals,
 
 		frames:     frames,
 		frameIndex: 1,
+
+		limits: lim,
 	}
+	vm.memory = limits.NewAccountant(lim.MaxMemory, vm.liveSize)
+	return vm
+}
+
+// Steps returns the number of instructions executed so far.
+func (vm *VM) Steps() int64 {
+	return vm.steps
+}
+
+// PeakAllocated returns the most memory the run was measured to hold.
+func (vm *VM) PeakAllocated() int64 {
+	return vm.memory.Peak()
+}
+
+// TotalAllocated returns the estimated bytes allocated by the run so far.
+func (vm *VM) TotalAllocated() int64 {
+	return vm.memory.Total()
+}
+
+// liveSize measures the memory reachable from the stack, the globals and the
+// closures being run.
+func (vm *VM) liveSize() int64 {
+	var r types.Reachable
+	for _, obj := range vm.stack[:vm.sp] {
+		r.Add(obj)
+	}
+	for _, obj := range vm.globals {
+		r.Add(obj)
+	}
+	for _, frame := range vm.frames[:vm.frameIndex] {
+		r.Add(frame.cl)
+	}
+	return r.Size()
+}
+
+// pushAllocated charges a newly built object to the run's memory budget and
+// pushes it.
+func (vm *VM) pushAllocated(obj types.Object) error {
+	if err := vm.memory.Charge(obj); err != nil {
+		return err
+	}
+	return vm.push(obj)
 }
 
 func (vm *VM) currentFrame() *Frame {
 	return vm.frames[vm.frameIndex-1]
 }
 
-func (vm *VM) pushFrame(f *Frame) {
-	vm.frames[vm.frameIndex] = f
+func (vm *VM) pushFrame(f *Frame) error {
+	if vm.frameIndex >= vm.limits.MaxFrames {
+		return fmt.Errorf("%w (%d frames)", ErrMaxDepth, vm.limits.MaxFrames)
+	}
+
+	if vm.frameIndex < len(vm.frames) {
+		vm.frames[vm.frameIndex] = f
+	} else {
+		vm.frames = append(vm.frames, f)
+	}
 	vm.frameIndex++
+	return nil
 }
 
 func (vm *VM) popFrame() *Frame {
@@ -69,19 +179,101 @@ func (vm *VM) popFrame() *Frame {
 }
 
 func (vm *VM) Run() error {
-	for vm.currentFrame().ip < len(vm.currentFrame().cl.Fn.Instructions)-1 {
+	return vm.RunContext(context.Background())
+}
+
+// RunContext executes the bytecode until it finishes or ctx is done. The
+// context is polled every few instructions, so a script stuck in a loop
+// stops promptly with an *InterruptError. Errors raised by the script are
+// returned as a *RuntimeError.
+func (vm *VM) RunContext(ctx context.Context) error {
+	defer vm.memory.Measure() // include what the run holds at the end in the peak
+
+	err := vm.run(ctx, 0)
+	if err != nil {
+		err = vm.runtimeError(err, 0)
+		// Frames abandoned by the error must not keep aliasing stack
+		// slots that later calls will reuse.
+		vm.closeUpvalues(0)
+	}
+	return err
+}
+
+// Call calls fn, a closure or builtin produced by an earlier run of this VM,
+// with args and returns its result. Each call starts with a fresh
+// instruction and memory budget.
+func (vm *VM) Call(ctx context.Context, fn types.Object, args ...types.Object) (types.Object, error) {
+	vm.steps = 0
+	vm.memory = limits.NewAccountant(vm.limits.MaxMemory, vm.liveSize)
+	defer vm.memory.Measure()
+
+	baseFrame, baseSP := vm.frameIndex, vm.sp
+	reset := func() {
+		vm.closeUpvalues(baseSP)
+		vm.frameIndex, vm.sp = baseFrame, baseSP
+	}
+
+	if err := vm.push(fn); err != nil {
+		return nil, err
+	}
+	for _, arg := range args {
+		if err := vm.push(arg); err != nil {
+			reset()
+			return nil, err
+		}
+	}
+
+	if err := vm.executeCall(len(args)); err != nil {
+		reset()
+		return nil, err
+	}
+	if err := vm.run(ctx, baseFrame); err != nil {
+		err = vm.runtimeError(err, baseFrame)
+		reset()
+		return nil, err
+	}
+
+	result := vm.pop()
+	reset()
+	return result, nil
+}
+
+// run executes instructions until the frame at stopDepth returns, or until
+// the main frame runs out of instructions when stopDepth is 0.
+func (vm *VM) run(ctx context.Context, stopDepth int) error {
+	done := ctx.Done()
+	ticks := 0
+
+	for vm.frameIndex > stopDepth && vm.currentFrame().ip < len(vm.currentFrame().cl.Fn.Instructions)-1 {
 		vm.currentFrame().ip++
 
+		vm.steps++
+		if vm.limits.MaxInstructions > 0 && vm.steps > vm.limits.MaxInstructions {
+			return fmt.Errorf("%w (%d instructions)", ErrBudgetExceeded, vm.limits.MaxInstructions)
+		}
+
+		if done != nil {
+			ticks++
+			if ticks == interruptCheckInterval {
+				ticks = 0
+				select {
+				case <-done:
+					return vm.interrupted(ctx.Err())
+				default:
+				}
+			}
+		}
+
 		top := vm.currentFrame().cl.Fn.Instructions[vm.currentFrame().ip]
 
-		_, err := Lookup(top)
+		_, err := code.Lookup(top)
 		if err != nil {
 			return err
 		}
 
-		op := Opcode(top)
+		op := code.Opcode(top)
 		switch op {
-		case OpConstant:
+		case code.OpConstant:
 			constIndex := binary.BigEndian.Uint16(vm.currentFrame().cl.Fn.Instructions[vm.currentFrame().ip+1:])
 			vm.currentFrame().ip += 2
 			err := vm.push(vm.constants[constIndex])
@@ -89,32 +281,34 @@ func (vm *VM) Run() error {
 				return err
 			}
 
-		case OpAdd, OpSub, OpMul, OpDiv, OpMod, OpEqual, OpNotEqual, OpGreaterThan, OpLessThan, OpGreaterOrEqual, OpLessOrEqual:
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod, code.OpEqual, code.OpNotEqual,
+			code.OpGreaterThan, code.OpLessThan, code.OpGreaterOrEqual, code.OpLessOrEqual,
+			code.OpBitAnd, code.OpBitOr, code.OpBitXor, code.OpBitAndNot, code.OpShiftLeft, code.OpShiftRight:
 			err := vm.executeBinaryOperation(op)
 			if err != nil {
 				return err
 			}
 
-		case OpMinus:
+		case code.OpMinus:
 			err := vm.executeMinusOperator()
 			if err != nil {
 				return err
 			}
 
-		case OpBang:
+		case code.OpBang:
 			err := vm.executeBangOperator()
 			if err != nil {
 				return err
 			}
 
-		case OpTrue:
+		case code.OpTrue:
 			vm.push(types.TRUE)
-		case OpFalse:
+		case code.OpFalse:
 			vm.push(types.FALSE)
-		case OpNull:
+		case code.OpNull:
 			vm.push(types.NULL)
 
-		case OpJumpNotTruthy:
+		case code.OpJumpNotTruthy:
 			pos := int(binary.BigEndian.Uint16(vm.currentFrame().cl.Fn.Instructions[vm.currentFrame().ip+1:]))
 			vm.currentFrame().ip += 2
 			condition := vm.pop()
@@ -122,45 +316,97 @@ func (vm *VM) Run() error {
 				vm.currentFrame().ip = pos - 1
 			}
 
-		case OpJump:
+		case code.OpJump:
 			pos := int(binary.BigEndian.Uint16(vm.currentFrame().cl.Fn.Instructions[vm.currentFrame().ip+1:]))
 			vm.currentFrame().ip = pos - 1
 
-		case OpSetGlobal:
+		case code.OpIterInit:
+			iter, err := types.NewIterator(vm.pop())
+			if err != nil {
+				return err
+			}
+			err = vm.push(iter)
+			if err != nil {
+				return err
+			}
+
+		case code.OpIterNext:
+			pos := int(binary.BigEndian.Uint16(vm.currentFrame().cl.Fn.Instructions[vm.currentFrame().ip+1:]))
+			vm.currentFrame().ip += 2
+			err := vm.executeIterNext(pos)
+			if err != nil {
+				return err
+			}
+
+		case code.OpCaseJump:
+			pos := int(binary.BigEndian.Uint16(vm.currentFrame().cl.Fn.Instructions[vm.currentFrame().ip+1:]))
+			vm.currentFrame().ip += 2
+			value := vm.pop()
+			if types.Equal(vm.stack[vm.sp-1], value) {
+				vm.pop()
+				vm.currentFrame().ip = pos - 1
+			}
+
+		case code.OpJumpTable:
+			constIndex := binary.BigEndian.Uint16(vm.currentFrame().cl.Fn.Instructions[vm.currentFrame().ip+1:])
+			table := vm.constants[constIndex].(*types.JumpTable)
+			vm.currentFrame().ip = table.Target(vm.pop()) - 1
+
+		case code.OpTypeOf:
+			err := vm.pushAllocated(&types.String{Value: string(vm.pop().Type())})
+			if err != nil {
+				return err
+			}
+
+		case code.OpSetGlobal:
 			globalIndex := binary.BigEndian.Uint16(vm.currentFrame().cl.Fn.Instructions[vm.currentFrame().ip+1:])
 			vm.currentFrame().ip += 2
 			vm.globals[globalIndex] = vm.pop()
 
-		case OpGetGlobal:
+		case code.OpGetGlobal:
 			globalIndex := binary.BigEndian.Uint16(vm.currentFrame().cl.Fn.Instructions[vm.currentFrame().ip+1:])
 			vm.currentFrame().ip += 2
-			vm.push(vm.globals[globalIndex])
+			global := vm.globals[globalIndex]
+			if global == nil {
+				return fmt.Errorf("%w (slot %d)", ErrUndefinedGlobal, globalIndex)
+			}
+			err := vm.push(global)
+			if err != nil {
+				return err
+			}
 
-		case OpSetLocal:
+		case code.OpSetLocal:
 			localIndex := int(vm.currentFrame().cl.Fn.Instructions[vm.currentFrame().ip+1])
 			vm.currentFrame().ip++
 			frame := vm.currentFrame()
 			vm.stack[frame.basePointer+localIndex] = vm.pop()
 
-		case OpGetLocal:
+		case code.OpGetLocal:
 			localIndex := int(vm.currentFrame().cl.Fn.Instructions[vm.currentFrame().ip+1])
 			vm.currentFrame().ip++
 			frame := vm.currentFrame()
-			vm.push(vm.stack[frame.basePointer+localIndex])
+			local := vm.stack[frame.basePointer+localIndex]
+			if local == nil {
+				return ErrUninitialized
+			}
+			err := vm.push(local)
+			if err != nil {
+				return err
+			}
 
-		case OpArray:
+		case code.OpArray:
 			err := vm.executeArrayLiteral()
 			if err != nil {
 				return err
 			}
 
-		case OpHash:
+		case code.OpHash:
 			err := vm.executeHashLiteral()
 			if err != nil {
 				return err
 			}
 
-		case OpIndex:
+		case code.OpIndex:
 			index := vm.pop()
 			left := vm.pop()
 			err := vm.executeIndexExpression(left, index)
@@ -168,7 +414,50 @@ func (vm *VM) Run() error {
 				return err
 			}
 
-		case OpCall:
+		case code.OpSetIndex:
+			value := vm.pop()
+			index := vm.pop()
+			obj := vm.pop()
+			before := types.SizeOf(obj)
+			err := types.SetIndex(obj, index, value)
+			if err != nil {
+				return err
+			}
+			err = vm.memory.ChargeGrowth(before, obj)
+			if err != nil {
+				return err
+			}
+
+		case code.OpGetProperty:
+			nameIndex := binary.BigEndian.Uint16(vm.currentFrame().cl.Fn.Instructions[vm.currentFrame().ip+1:])
+			vm.currentFrame().ip += 2
+			name := vm.constants[nameIndex].(*types.String).Value
+			result, err := types.GetProperty(vm.pop(), name)
+			if err != nil {
+				return err
+			}
+			err = vm.push(result)
+			if err != nil {
+				return err
+			}
+
+		case code.OpSetProperty:
+			nameIndex := binary.BigEndian.Uint16(vm.currentFrame().cl.Fn.Instructions[vm.currentFrame().ip+1:])
+			vm.currentFrame().ip += 2
+			name := vm.constants[nameIndex].(*types.String).Value
+			value := vm.pop()
+			obj := vm.pop()
+			before := types.SizeOf(obj)
+			err := types.SetProperty(obj, name, value)
+			if err != nil {
+				return err
+			}
+			err = vm.memory.ChargeGrowth(before, obj)
+			if err != nil {
+				return err
+			}
+
+		case code.OpCall:
 			numArgs := int(vm.currentFrame().cl.Fn.Instructions[vm.currentFrame().ip+1])
 			vm.currentFrame().ip++
 			err := vm.executeCall(int(numArgs))
@@ -176,29 +465,71 @@ func (vm *VM) Run() error {
 				return err
 			}
 
-		case OpReturnValue:
+		case code.OpReturnValue:
 			returnValue := vm.pop()
-			vm.popFrame()
-			vm.pop() // Pop function from stack
+			frame := vm.popFrame()
+			vm.closeUpvalues(frame.basePointer)
+			vm.sp = frame.basePointer - 1 // Drop locals and the function
 			vm.push(returnValue)
 
-		case OpReturn:
-			vm.popFrame()
-			vm.pop() // Pop function from stack
+		case code.OpReturn:
+			frame := vm.popFrame()
+			vm.closeUpvalues(frame.basePointer)
+			vm.sp = frame.basePointer - 1 // Drop locals and the function
 			vm.push(types.NULL)
 
-		case OpGetBuiltin:
-			builtinIndex := vm.currentFrame().cl.Fn.Instructions[vm.currentFrame().ip+1]
+		case code.OpGetBuiltin:
+			builtinIndex := int(binary.BigEndian.Uint16(vm.currentFrame().cl.Fn.Instructions[vm.currentFrame().ip+1:]))
+			vm.currentFrame().ip += 2
+			builtin := vm.builtins.At(builtinIndex)
+			if builtin == nil {
+				return fmt.Errorf("undefined builtin %d", builtinIndex)
+			}
+			err := vm.push(builtin)
+			if err != nil {
+				return err
+			}
+
+		case code.OpGetFree:
+			freeIndex := int(vm.currentFrame().cl.Fn.Instructions[vm.currentFrame().ip+1])
 			vm.currentFrame().ip++
-			definition := types.Builtins[builtinIndex]
-			vm.push(definition.Builtin)
+			free := vm.currentFrame().cl.Upvalues[freeIndex].Get()
+			if free == nil {
+				return ErrUninitialized
+			}
+			err := vm.push(free)
+			if err != nil {
+				return err
+			}
 
-		case OpGetFree:
+		case code.OpSetFree:
 			freeIndex := int(vm.currentFrame().cl.Fn.Instructions[vm.currentFrame().ip+1])
 			vm.currentFrame().ip++
-			vm.push(vm.currentFrame().cl.FreeVariables[freeIndex])
+			vm.currentFrame().cl.Upvalues[freeIndex].Set(vm.pop())
 
-		case OpClosure:
+		case code.OpCaptureLocal:
+			localIndex := int(vm.currentFrame().cl.Fn.Instructions[vm.currentFrame().ip+1])
+			vm.currentFrame().ip++
+			err := vm.push(vm.captureUpvalue(vm.currentFrame().basePointer + localIndex))
+			if err != nil {
+				return err
+			}
+
+		case code.OpCurrentClosure:
+			err := vm.push(vm.currentFrame().cl)
+			if err != nil {
+				return err
+			}
+
+		case code.OpCaptureFree:
+			freeIndex := int(vm.currentFrame().cl.Fn.Instructions[vm.currentFrame().ip+1])
+			vm.currentFrame().ip++
+			err := vm.push(vm.currentFrame().cl.Upvalues[freeIndex])
+			if err != nil {
+				return err
+			}
+
+		case code.OpClosure:
 			constIndex := binary.BigEndian.Uint16(vm.currentFrame().cl.Fn.Instructions[vm.currentFrame().ip+1:])
 			numFree := int(vm.currentFrame().cl.Fn.Instructions[vm.currentFrame().ip+3])
 			vm.currentFrame().ip += 3
@@ -207,7 +538,7 @@ func (vm *VM) Run() error {
 				return err
 			}
 
-		case OpPop:
+		case code.OpPop:
 			vm.pop()
 		}
 	}
@@ -215,6 +546,37 @@ func (vm *VM) Run() error {
 	return nil
 }
 
+// runtimeError attaches the position of the failing instruction and the
+// stack of frames above bottom to err.
+func (vm *VM) runtimeError(err error, bottom int) error {
+	stack := make([]types.StackFrame, 0, vm.frameIndex-bottom)
+	for i := vm.frameIndex - 1; i >= bottom; i-- {
+		frame := vm.frames[i]
+		stack = append(stack, types.StackFrame{
+			Function: frame.cl.Fn.DisplayName(),
+			Pos:      frame.cl.Fn.Positions.Lookup(frame.ip),
+		})
+	}
+	rerr := &RuntimeError{Err: err, Stack: stack}
+	if len(stack) > 0 {
+		rerr.Pos = stack[0].Pos
+	}
+	return rerr
+}
+
+// interrupted builds the error returned when the context stops a run.
+func (vm *VM) interrupted(err error) error {
+	frame := vm.currentFrame()
+	op := "?"
+	if def, lerr := code.Lookup(frame.cl.Fn.Instructions[frame.ip]); lerr == nil {
+		op = def.Name
+	}
+	return &limits.InterruptError{
+		Err:      err,
+		Location: fmt.Sprintf("instruction %04d (%s), call depth %d", frame.ip, op, vm.frameIndex),
+	}
+}
+
 func (vm *VM) pushClosure(constIndex int, numFree int) error {
 	constant := vm.constants[constIndex]
 	function, ok := constant.(*types.CompiledFunction)
@@ -222,14 +584,23 @@ func (vm *VM) pushClosure(constIndex int, numFree int) error {
 		return fmt.Errorf("not a function: %+v", constant)
 	}
 
-	free := make([]types.Object, numFree)
+	// Captured variables arrive as upvalues; a plain value, such as the
+	// enclosing closure itself, is captured as an already closed one.
+	upvalues := make([]*types.Upvalue, numFree)
 	for i := 0; i < numFree; i++ {
-		free[i] = vm.stack[vm.sp-numFree+i]
+		switch captured := vm.stack[vm.sp-numFree+i].(type) {
+		case *types.Upvalue:
+			upvalues[i] = captured
+		default:
+			uv := &types.Upvalue{Closed: captured}
+			uv.Location = &uv.Closed
+			upvalues[i] = uv
+		}
 	}
 	vm.sp = vm.sp - numFree
 
-	closure := &types.Closure{Fn: function, FreeVariables: free}
-	return vm.push(closure)
+	closure := &types.Closure{Fn: function, Upvalues: upvalues}
+	return vm.pushAllocated(closure)
 }
 
 func isTruthy(obj types.Object) bool {
@@ -0,0 +1,453 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pannagaperumal/moxy/ast"
+	"github.com/pannagaperumal/moxy/internal/code"
+	"github.com/pannagaperumal/moxy/internal/compiler"
+	"github.com/pannagaperumal/moxy/internal/evaluator"
+	"github.com/pannagaperumal/moxy/internal/lexer"
+	"github.com/pannagaperumal/moxy/internal/parser"
+	"github.com/pannagaperumal/moxy/types"
+)
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		limits   Limits
+		input    string
+		expected error // nil means the program must finish
+	}{
+		{Limits{MaxInstructions: 1000}, `for true {}`, ErrBudgetExceeded},
+		{Limits{MaxInstructions: 1000}, `var n = 0; for n < 10 { n = n + 1 }`, nil},
+		{Limits{}, `var f = func(f, n) { f(f, n + 1) }; f(f, 0)`, ErrMaxDepth},
+		{Limits{MaxFrames: 10}, `var f = func(f, n) { if (n == 0) { return 0 } f(f, n - 1) }; f(f, 20)`, ErrMaxDepth},
+		{Limits{MaxFrames: 10}, `var f = func(f, n) { if (n == 0) { return 0 } f(f, n - 1) }; f(f, 5)`, nil},
+		{Limits{MaxStack: 8}, `[1, 2, 3, 4, 5, 6, 7, 8, 9]`, ErrStackOverflow},
+	}
+
+	for i, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(t, tt.input)); err != nil {
+			t.Fatalf("tests[%d] - compiler error: %s", i, err)
+		}
+
+		err := NewWithLimits(comp.Bytecode(), tt.limits).Run()
+		if !errors.Is(err, tt.expected) {
+			t.Fatalf("tests[%d] - expected error %v, got=%v", i, tt.expected, err)
+		}
+	}
+}
+
+func TestStepsCountInstructions(t *testing.T) {
+	comp := compiler.New()
+	if err := comp.Compile(parse(t, `1 + 2`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	// OpConstant, OpConstant, OpAdd, OpPop
+	machine := NewWithLimits(comp.Bytecode(), Limits{MaxInstructions: 4})
+	if err := machine.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	if machine.Steps() != 4 {
+		t.Fatalf("expected 4 steps, got=%d", machine.Steps())
+	}
+}
+
+func TestMemoryLimit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected error // nil means the program must finish
+	}{
+		{`var s = "x"; for true { s = s + s }`, ErrMemoryLimit},
+		{`var a = []; for true { a = [a, a, "item"] }`, ErrMemoryLimit},
+		{`var fs = []; for true { fs = [fs, func() { fs }] }`, ErrMemoryLimit},
+		// Each iteration drops the previous string, so only the last one is live.
+		{`var s = ""; for i := 0; i < 10000; i = i + 1 { s = s + "x" }`, nil},
+		{`var n = 0; for n < 10000 { var h = {"n": [n, n, n]}; n = n + 1 }`, nil},
+	}
+
+	for i, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(t, tt.input)); err != nil {
+			t.Fatalf("tests[%d] - compiler error: %s", i, err)
+		}
+
+		machine := NewWithLimits(comp.Bytecode(), Limits{MaxMemory: 1 << 20})
+		err := machine.Run()
+		if !errors.Is(err, tt.expected) {
+			t.Fatalf("tests[%d] - expected error %v, got=%v", i, tt.expected, err)
+		}
+		if tt.expected == nil && machine.PeakAllocated() >= machine.TotalAllocated() {
+			t.Fatalf("tests[%d] - peak %d should stay below total %d", i, machine.PeakAllocated(), machine.TotalAllocated())
+		}
+	}
+}
+
+func TestUpvalueCells(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`var count = 0; var inc = func() { count = count + 1 }; inc(); inc(); count`, "2"},
+		{`var counter = func() { var n = 0; return func() { n = n + 1; n } }; var c = counter(); c(); c(); c()`, "3"},
+		{`var counter = func() { var n = 0; return func() { n = n + 1; n } }; var a = counter(); var b = counter(); a(); a(); b()`, "1"},
+		{`var pair = func() { var n = 0; return [func() { n = n + 1 }, func() { n }] }; var p = pair(); p[0](); p[0](); p[1]()`, "2"},
+		{`var outer = func() { var n = 1; var mid = func() { return func() { n = n * 10 } }; mid()(); mid()(); n }; outer()`, "100"},
+		{`var f = func() { var n = 0; var set = func(v) { n = v }; set(5); var get = func() { n }; set(7); get() }; f()`, "7"},
+		{`var fs = []
+for i := 0; i < 3; i = i + 1 { var j = i; fs = [fs, func() { j }] }
+[fs[0][1](), fs[1]()]`, "[2, 2]"},
+	}
+
+	for i, tt := range tests {
+		program := parse(t, tt.input)
+
+		vmResult := runVM(t, program)
+		evalResult := runEval(t, program)
+
+		if vmResult != tt.expected {
+			t.Fatalf("tests[%d] - VM result wrong. expected=%s, got=%s", i, tt.expected, vmResult)
+		}
+		if evalResult != vmResult {
+			t.Fatalf("tests[%d] - engines disagree. VM=%s, evaluator=%s", i, vmResult, evalResult)
+		}
+	}
+}
+
+// A run that fails inside a function must not leave upvalues aliasing the
+// stack slots the next run reuses.
+func TestUpvaluesClosedOnError(t *testing.T) {
+	comp := compiler.New()
+	input := `var keep = func() { var n = 1; var get = func() { n }; 1 + "a"; get }; keep()`
+	if err := comp.Compile(parse(t, input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	machine := New(comp.Bytecode())
+	if err := machine.Run(); err == nil {
+		t.Fatalf("expected a type mismatch")
+	}
+	if len(machine.openUpvalues) != 0 {
+		t.Fatalf("expected no open upvalues after the error, got=%d", len(machine.openUpvalues))
+	}
+}
+
+func TestRecursiveFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`func fact(n) { if (n < 2) { return 1 } n * fact(n - 1) }
+fact(5)`, "120"},
+		{`var fact = func(n) { if (n < 2) { return 1 } n * fact(n - 1) }; fact(5)`, "120"},
+		{`var ev = func(n) { if (n == 0) { return true } od(n - 1) }; var od = func(n) { if (n == 0) { return false } ev(n - 1) }; ev(7)`, "false"},
+		{`func f() {
+	func ev(n) { if (n == 0) { return true } od(n - 1) }
+	var od = func(n) { if (n == 0) { return false } ev(n - 1) }
+	od(3)
+}
+f()`, "true"},
+		{`func f() { g() }
+func g() { 42 }
+f()`, "42"},
+		// A redeclaration defines a new variable when it runs; only the
+		// first binding is hoisted.
+		{`var f = func() { 1 }; var g = f(); var f = func() { 2 }; [g, f()]`, "[1, 2]"},
+		{`var h = func() { var f = func() { 1 }; var g = f(); var f = func() { 2 }; [g, f()] }; h()`, "[1, 2]"},
+		{`var f = 5; var f = func() { 2 }; f()`, "2"},
+		{`var h = func(f) { var g = f; var f = func() { g + 1 }; f() }; h(3)`, "4"},
+	}
+
+	for i, tt := range tests {
+		program := parse(t, tt.input)
+
+		vmResult := runVM(t, program)
+		evalResult := runEval(t, program)
+
+		if vmResult != tt.expected {
+			t.Fatalf("tests[%d] - VM result wrong. expected=%s, got=%s", i, tt.expected, vmResult)
+		}
+		if evalResult != vmResult {
+			t.Fatalf("tests[%d] - engines disagree. VM=%s, evaluator=%s", i, vmResult, evalResult)
+		}
+	}
+}
+
+func TestOperators(t *testing.T) {
+	values := []struct {
+		input    string
+		expected string
+	}{
+		{"7 % 3", "1"},
+		{"-7 % 3", "-1"},
+		{"[5 <= 5, 5 <= 4, 6 >= 7, 7 >= 7]", "[true, false, false, true]"},
+		{"[6 & 3, 6 | 3, 6 ^ 3, 6 &^ 3]", "[2, 7, 5, 4]"},
+		{"[1 << 4, -16 >> 2, 1 << 64]", "[16, -4, 0]"},
+		{"1 + 2 * 3 % 4", "3"},
+		{"1 | 2 == 3", "true"},
+		{"[true && false, false || 1, 0 && 1, true || false]", "[false, true, true, true]"},
+		// && and || do not evaluate their right operand when the left
+		// decides the result.
+		{`var n = 0; var bump = func() { n = n + 1; true }; false && bump(); true || bump(); n`, "0"},
+		{`var n = 0; var bump = func() { n = n + 1; true }; true && bump(); false || bump(); n`, "2"},
+	}
+
+	for _, tt := range values {
+		program := parse(t, tt.input)
+		if got := runVM(t, program); got != tt.expected {
+			t.Fatalf("%q - VM: expected=%s, got=%s", tt.input, tt.expected, got)
+		}
+		if got := runEval(t, program); got != tt.expected {
+			t.Fatalf("%q - evaluator: expected=%s, got=%s", tt.input, tt.expected, got)
+		}
+	}
+
+	errs := []struct {
+		input    string
+		expected string
+	}{
+		{"7 % 0", "modulo by zero"},
+		{"1 << -1", "negative shift count: -1"},
+		{"1 >> -2", "negative shift count: -2"},
+	}
+
+	for _, tt := range errs {
+		program := parse(t, tt.input)
+
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			t.Fatalf("%q - compiler error: %s", tt.input, err)
+		}
+		var rerr *RuntimeError
+		if err := New(comp.Bytecode()).Run(); !errors.As(err, &rerr) || rerr.Err.Error() != tt.expected {
+			t.Fatalf("%q - VM: expected %q, got=%v", tt.input, tt.expected, err)
+		}
+
+		result := evaluator.New(context.Background()).Eval(program, types.NewEnvironment())
+		if errObj, ok := result.(*types.Error); !ok || errObj.Message != tt.expected {
+			t.Fatalf("%q - evaluator: expected %q, got=%s", tt.input, tt.expected, inspect(result))
+		}
+	}
+}
+
+func TestFloats(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1.5 + 2.25", "3.75"},
+		{"0.1 + 0.2", "0.30000000000000004"},
+		{"3 / 2.0", "1.5"},
+		{"2.5 - 3", "-0.5"},
+		{"1.0 / 0", "+Inf"},
+		{"-(2 - 3.5)", "1.5"},
+		{"[1.5 < 2, 2 <= 2.0, 3.5 > 3, 1.0 == 1, 1.5 != 1.5]", "[true, true, true, true, false]"},
+		{"var half = func(x) { x * 0.5 }; half(3)", "1.5"},
+		{"var total = 0.0\nvar i = 0\nfor i < 4 { total = total + 0.25; i = i + 1 }\ntotal", "1"},
+		// An if whose branch ends in an assignment must not pop the local
+		// the result is computed from.
+		{"var price = func(p) { var d = 0.0; if (p > 100) { d = 0.1 }; p - p * d }; [price(200), price(50)]", "[180, 50]"},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			t.Fatalf("%q - compiler error: %s", tt.input, err)
+		}
+		machine := New(comp.Bytecode())
+		if err := machine.Run(); err != nil {
+			t.Fatalf("%q - vm error: %s", tt.input, err)
+		}
+		result := machine.LastPoppedStackElem()
+		if got := inspect(result); got != tt.expected {
+			t.Fatalf("%q - expected=%s, got=%s", tt.input, tt.expected, got)
+		}
+		if _, isInt := result.(*types.Integer); isInt {
+			t.Fatalf("%q - expected a float, got an integer", tt.input)
+		}
+		if got := runEval(t, program); got != tt.expected {
+			t.Fatalf("%q - evaluator disagrees: %s", tt.input, got)
+		}
+	}
+
+	for _, input := range []string{"1.5 % 1.0", "1.0 & 1", "2 << 1.0"} {
+		comp := compiler.New()
+		if err := comp.Compile(parse(t, input)); err != nil {
+			t.Fatalf("%q - compiler error: %s", input, err)
+		}
+		if err := New(comp.Bytecode()).Run(); err == nil {
+			t.Fatalf("%q - expected an error", input)
+		}
+	}
+}
+
+func TestStackTrace(t *testing.T) {
+	input := `func inner(x) {
+  x + true
+}
+var outer = func() {
+  func() { inner(1) }()
+}
+func wrap() { outer() }
+wrap`
+
+	comp := compiler.New()
+	if err := comp.Compile(parse(t, input+"()")); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	err := New(comp.Bytecode()).Run()
+
+	var rerr *RuntimeError
+	if !errors.As(err, &rerr) {
+		t.Fatalf("expected a *RuntimeError, got=%T (%v)", err, err)
+	}
+	expected := "at inner (2:5)\nat <anonymous> (5:17)\nat outer (5:22)\nat wrap (7:20)\nat <main> (8:5)"
+	if got := rerr.StackTrace(); got != expected {
+		t.Fatalf("stack wrong.\nexpected=%q\ngot=%q", expected, got)
+	}
+	if rerr.Pos != rerr.Stack[0].Pos {
+		t.Fatalf("expected Pos to be the innermost frame's, got=%s", rerr.Pos)
+	}
+
+	// A host call only reports the frames above the call.
+	comp = compiler.New()
+	if err := comp.Compile(parse(t, input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	machine := New(comp.Bytecode())
+	if err := machine.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	_, err = machine.Call(context.Background(), machine.LastPoppedStackElem())
+	if !errors.As(err, &rerr) {
+		t.Fatalf("expected a *RuntimeError from Call, got=%T (%v)", err, err)
+	}
+	expected = "at inner (2:5)\nat <anonymous> (5:17)\nat outer (5:22)\nat wrap (7:20)"
+	if got := rerr.StackTrace(); got != expected {
+		t.Fatalf("Call stack wrong.\nexpected=%q\ngot=%q", expected, got)
+	}
+}
+
+func TestSwitchDispatch(t *testing.T) {
+	dense := `func f(x) { var r = "none"; switch x { case 1: r = "one" case 2: r = "two"; fallthrough case 3: r = r + "+three" case 4, 5: r = "four or five" default: r = "other" }; r }; `
+
+	tests := []struct {
+		input    string
+		expected string
+		opcode   string // the dispatch instruction the compiler must choose
+	}{
+		{dense + `[f(1), f(2), f(3), f(5), f(6), f(0)]`, "[one, two+three, none+three, four or five, other, other]", "OpJumpTable"},
+		{dense + `[f(2.0), f(2.5), f("2"), f(-1)]`, "[two+three, other, other, other]", "OpJumpTable"},
+		{`func f(x) { switch x { case 1: return "a" case 100: return "b" case 1000: return "c" case 10000: return "d" }; "none" }; [f(100), f(10000), f(5)]`, "[b, d, none]", "OpCaseJump"},
+		{`func f(x) { switch x { case 1: return "a" case 2: return "b" }; "none" }; [f(1), f(2), f(3)]`, "[a, b, none]", "OpCaseJump"},
+		{`func f(x) { switch x { case "a": return 1 case "b": return 2 case "c": return 3 case "d": return 4 }; 0 }; [f("c"), f("e")]`, "[3, 0]", "OpCaseJump"},
+		{`func f(x) { switch v := x.(type) { case int, float: return v * 2 case string: return v + v case nil: return "nil" default: return "other" } }; [f(2), f(1.5), f("a"), f([]), f(f(""))]`, "[4, 3, aa, other, ]", "OpTypeOf"},
+		// The binding shadows an outer variable only inside the switch.
+		{`var v = "outer"; var inside = 0; switch v := 1.(type) { case int: inside = v + 1 }; [inside, v]`, "[2, outer]", "OpTypeOf"},
+	}
+
+	for i, tt := range tests {
+		program := parse(t, tt.input)
+
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			t.Fatalf("tests[%d] - compiler error: %s", i, err)
+		}
+		if !usesOpcode(comp.Bytecode(), tt.opcode) {
+			t.Fatalf("tests[%d] - expected the switch to compile to %s", i, tt.opcode)
+		}
+
+		vmResult := runVM(t, program)
+		evalResult := runEval(t, program)
+
+		if vmResult != tt.expected {
+			t.Fatalf("tests[%d] - VM result wrong. expected=%s, got=%s", i, tt.expected, vmResult)
+		}
+		if evalResult != vmResult {
+			t.Fatalf("tests[%d] - engines disagree. VM=%s, evaluator=%s", i, vmResult, evalResult)
+		}
+	}
+}
+
+func TestSwitchBindingScope(t *testing.T) {
+	program := parse(t, "switch v := 1.(type) { case int: v }\nv")
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err == nil || !strings.Contains(err.Error(), "undefined variable v") {
+		t.Fatalf("expected v to be undefined after the switch, got=%v", err)
+	}
+
+	env := types.NewEnvironment()
+	result := evaluator.New(context.Background()).Eval(program, env)
+	if errObj, ok := result.(*types.Error); !ok || errObj.Message != "identifier not found: v" {
+		t.Fatalf("expected v to be undefined after the switch, got=%s", inspect(result))
+	}
+}
+
+// usesOpcode reports whether the main program or any function constant in
+// bytecode contains the named instruction.
+func usesOpcode(bytecode *compiler.Bytecode, name string) bool {
+	if strings.Contains(bytecode.Instructions.String(), name) {
+		return true
+	}
+	for _, c := range bytecode.Constants {
+		if fn, ok := c.(*types.CompiledFunction); ok && strings.Contains(code.Instructions(fn.Instructions).String(), name) {
+			return true
+		}
+	}
+	return false
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return program
+}
+
+// runVM compiles and runs program and returns the inspected result.
+func runVM(t *testing.T, program *ast.Program) string {
+	t.Helper()
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	machine := New(comp.Bytecode())
+	if err := machine.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	return inspect(machine.LastPoppedStackElem())
+}
+
+// runEval evaluates program and returns the inspected result.
+func runEval(t *testing.T, program *ast.Program) string {
+	t.Helper()
+
+	env := types.NewEnvironment()
+	evaluator.RegisterBuiltins(env)
+	result := evaluator.New(context.Background()).Eval(program, env)
+	if errObj, ok := result.(*types.Error); ok {
+		t.Fatalf("evaluator error: %s", errObj.Message)
+	}
+	return inspect(result)
+}
+
+func inspect(obj types.Object) string {
+	if obj == nil {
+		return types.NULL.Inspect()
+	}
+	return obj.Inspect()
+}
@@ -1,13 +1,17 @@
 package moxy
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
+
 	"github.com/pannagaperumal/moxy/internal/compiler"
 	"github.com/pannagaperumal/moxy/internal/evaluator"
 	"github.com/pannagaperumal/moxy/internal/lexer"
+	"github.com/pannagaperumal/moxy/internal/limits"
 	"github.com/pannagaperumal/moxy/internal/parser"
+	"github.com/pannagaperumal/moxy/internal/symbol"
 	"github.com/pannagaperumal/moxy/internal/vm"
 	"github.com/pannagaperumal/moxy/types"
 )
@@ -16,29 +20,88 @@ import (
 // Similar to lua_State.
 type State struct {
 	Env *types.Environment
+
+	// Limits bounds every run started from this State, on both engines.
+	Limits Limits
+
+	builtins *types.BuiltinTable
+	stats    Stats
+
+	// VM session: globals defined by RunVM persist across runs and can be
+	// called through CallVM.
+	vmSymbols   *compiler.SymbolTable
+	vmConstants []types.Object
+	vmGlobals   []types.Object
+	vm          *vm.VM
+}
+
+// Stats describes the resources used by a run.
+type Stats struct {
+	// Instructions is the number of VM instructions executed, or AST
+	// nodes evaluated when the evaluator ran the script.
+	Instructions int64
+
+	// PeakAllocated is the most memory, in estimated bytes of strings,
+	// arrays, hashes and closures, the script was measured to hold at
+	// once. This is what Limits.MaxMemory caps.
+	PeakAllocated int64
+
+	// TotalAllocated is the estimated number of bytes the script
+	// allocated over the run, including values that became garbage.
+	TotalAllocated int64
 }
 
 // New creates a new Moxy interpreter state with built-ins registered.
 func New() *State {
+	env := types.NewEnvironment()
+	evaluator.RegisterBuiltins(env)
+
 	return &State{
-		Env: types.NewEnvironment(),
+		Env:      env,
+		builtins: types.NewBuiltinTable(),
 	}
 }
 
+// Limits bounds the instructions, call depth and stack a run may use.
+type Limits = vm.Limits
+
+// Errors returned when a run goes over its Limits.
+var (
+	ErrBudgetExceeded = vm.ErrBudgetExceeded
+	ErrMaxDepth       = vm.ErrMaxDepth
+	ErrStackOverflow  = vm.ErrStackOverflow
+	ErrMemoryLimit    = vm.ErrMemoryLimit
+)
+
+// InterruptError is returned when a run is stopped by its context. It records
+// where in the script execution stopped.
+type InterruptError = limits.InterruptError
+
 // Run executes the code using the Evaluator (Feature-complete, best for plugins).
 func (s *State) Run(code string) (types.Object, error) {
-	l := lexer.New(code)
+	return s.RunContext(context.Background(), code)
+}
+
+// RunContext is like Run but stops with an *InterruptError once ctx is done.
+func (s *State) RunContext(ctx context.Context, code string) (types.Object, error) {
+	return s.run(ctx, "", code)
+}
+
+// run evaluates code, naming file in error positions.
+func (s *State) run(ctx context.Context, file, code string) (types.Object, error) {
+	l := lexer.NewFile(file, code)
 	p := parser.New(l)
 	program := p.ParseProgram()
 
 	if len(p.Errors()) != 0 {
-		return nil, fmt.Errorf("parser errors: %v", p.Errors())
+		return nil, syntaxError(code, p)
 	}
 
-	evaluator.RegisterBuiltins(s.Env)
-	result := evaluator.Eval(program, s.Env)
-	if result != nil && result.Type() == types.ERROR_OBJ {
-		return nil, fmt.Errorf("runtime error: %s", result.Inspect())
+	eval := evaluator.NewWithLimits(ctx, s.Limits)
+	result := eval.Eval(program, s.Env)
+	s.stats = evalStats(eval)
+	if err := evalError(code, result); err != nil {
+		return nil, err
 	}
 
 	return result, nil
@@ -46,48 +109,133 @@ func (s *State) Run(code string) (types.Object, error) {
 
 // RunVM executes the code using the high-performance VM (Limited support for dynamic builtins).
 func (s *State) RunVM(code string) (types.Object, error) {
-	l := lexer.New(code)
+	return s.RunVMContext(context.Background(), code)
+}
+
+// RunVMContext is like RunVM but stops with an *InterruptError once ctx is done.
+func (s *State) RunVMContext(ctx context.Context, code string) (types.Object, error) {
+	return s.runVM(ctx, "", code)
+}
+
+// runVM compiles and runs code, naming file in error positions.
+func (s *State) runVM(ctx context.Context, file, code string) (types.Object, error) {
+	l := lexer.NewFile(file, code)
 	p := parser.New(l)
 	program := p.ParseProgram()
 
 	if len(p.Errors()) != 0 {
-		return nil, fmt.Errorf("parser errors: %v", p.Errors())
+		return nil, syntaxError(code, p)
 	}
 
-	comp := compiler.New()
+	var comp *compiler.Compiler
+	if s.vmSymbols == nil {
+		comp = compiler.NewWithBuiltins(s.builtins)
+	} else {
+		comp = compiler.NewWithState(s.vmSymbols, s.vmConstants, s.builtins)
+	}
 	err := comp.Compile(program)
 	if err != nil {
-		return nil, fmt.Errorf("compiler error: %s", err)
+		return nil, compileError(code, err)
+	}
+
+	bytecode := comp.Bytecode()
+	s.vmSymbols, s.vmConstants = comp.SymbolTable(), bytecode.Constants
+	if n := comp.NumGlobals(); n > len(s.vmGlobals) {
+		s.vmGlobals = append(s.vmGlobals, make([]types.Object, n-len(s.vmGlobals))...)
 	}
 
-	machine := vm.New(comp.Bytecode())
-	err = machine.Run()
+	machine := vm.NewWithGlobals(bytecode, s.vmGlobals, s.Limits)
+	s.vm = machine
+	err = machine.RunContext(ctx)
+	s.stats = vmStats(machine)
 	if err != nil {
-		return nil, fmt.Errorf("vm error: %s", err)
+		return nil, vmError(code, err)
 	}
 
 	return s.GetLastPopped(machine), nil
 }
 
+// Stats returns the resource usage of the most recent run or call.
+func (s *State) Stats() Stats {
+	return s.stats
+}
+
+func evalStats(e *evaluator.Evaluator) Stats {
+	return Stats{
+		Instructions:   e.Steps(),
+		PeakAllocated:  e.PeakAllocated(),
+		TotalAllocated: e.TotalAllocated(),
+	}
+}
+
+func vmStats(machine *vm.VM) Stats {
+	return Stats{
+		Instructions:   machine.Steps(),
+		PeakAllocated:  machine.PeakAllocated(),
+		TotalAllocated: machine.TotalAllocated(),
+	}
+}
+
 // GetLastPopped is a helper to get the result from the VM
 func (s *State) GetLastPopped(v *vm.VM) types.Object {
 	return v.LastPoppedStackElem()
 }
 
-// RunFile reads and executes a Moxy script file.
+// CallVM calls a function defined by an earlier RunVM.
+func (s *State) CallVM(funcName string, args ...any) (types.Object, error) {
+	return s.CallVMContext(context.Background(), funcName, args...)
+}
+
+// CallVMContext is like CallVM but stops with an *InterruptError once ctx is
+// done.
+func (s *State) CallVMContext(ctx context.Context, funcName string, args ...any) (types.Object, error) {
+	if s.vm == nil {
+		return nil, fmt.Errorf("function %s not found", funcName)
+	}
+
+	sym, ok := s.vmSymbols.Resolve(funcName)
+	if !ok || sym.Scope != symbol.GlobalScope || s.vmGlobals[sym.Index] == nil {
+		return nil, fmt.Errorf("function %s not found", funcName)
+	}
+
+	moxyArgs, err := convertArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.vm.Call(ctx, s.vmGlobals[sym.Index], moxyArgs...)
+	s.stats = vmStats(s.vm)
+	if err != nil {
+		return nil, vmError("", err)
+	}
+
+	return result, nil
+}
+
+// RunFile reads and executes a Moxy script file. Error positions name the
+// file.
 func (s *State) RunFile(path string) (types.Object, error) {
 	content, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
-	return s.Run(string(content))
+	return s.run(context.Background(), path, string(content))
+}
+
+// RunVMFile reads and executes a Moxy script file on the VM.
+func (s *State) RunVMFile(path string) (types.Object, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return s.runVM(context.Background(), path, string(content))
 }
 
 // SetGlobal sets a global variable in the interpreter environment.
 func (s *State) SetGlobal(name string, value any) error {
-	obj := convertToMoxyObject(value)
-	if obj == nil {
-		return fmt.Errorf("unsupported type: %T", value)
+	obj, err := types.FromGo(value)
+	if err != nil {
+		return fmt.Errorf("global %s: %w", name, err)
 	}
 	s.Env.Set(name, obj)
 	return nil
@@ -98,88 +246,74 @@ func (s *State) GetGlobal(name string) (types.Object, bool) {
 	return s.Env.Get(name)
 }
 
-// RegisterFunction registers a Go function as a Moxy builtin.
+// RegisterFunction registers a Go function as a Moxy builtin. Builtins are
+// scoped to the State, so States with different host APIs do not interfere.
 func (s *State) RegisterFunction(name string, fn func(args ...types.Object) types.Object) {
 	builtin := &types.Builtin{Fn: fn}
 
-	// Add to environment for Evaluator
 	s.Env.Set(name, builtin)
+	s.builtins.Register(name, builtin)
+}
 
-	// Also add to the global Builtins for VM (Workaround until VM is decentralized)
-	// We check if it's already there to avoid duplicates
-	found := false
-	for _, b := range types.Builtins {
-		if b.Name == name {
-			found = true
-			break
-		}
+// RegisterGoFunc registers an ordinary Go function, such as
+// func(user string, n int) (bool, error), as a Moxy builtin. Arguments are
+// checked and converted to the parameter types, results are converted back,
+// and a returned error fails the script with that error.
+func (s *State) RegisterGoFunc(name string, fn any) error {
+	builtin, err := types.WrapGoFunc(name, fn)
+	if err != nil {
+		return err
 	}
 
-	if !found {
-		types.Builtins = append(types.Builtins, struct {
-			Name    string
-			Builtin *types.Builtin
-		}{Name: name, Builtin: builtin})
-	}
+	s.Env.Set(name, builtin)
+	s.builtins.Register(name, builtin)
+	return nil
 }
 
 // Call calls a Moxy function defined in the state.
 func (s *State) Call(funcName string, args ...any) (types.Object, error) {
+	return s.CallContext(context.Background(), funcName, args...)
+}
+
+// CallContext is like Call but stops with an *InterruptError once ctx is done.
+func (s *State) CallContext(ctx context.Context, funcName string, args ...any) (types.Object, error) {
 	fnObj, ok := s.Env.Get(funcName)
 	if !ok {
 		return nil, fmt.Errorf("function %s not found", funcName)
 	}
 
-	pebbleArgs := make([]types.Object, len(args))
-	for i, arg := range args {
-		pebbleArgs[i] = convertToMoxyObject(arg)
+	pebbleArgs, err := convertArgs(args)
+	if err != nil {
+		return nil, err
 	}
 
-	result := evaluator.ApplyFunction(fnObj, pebbleArgs)
-	if result.Type() == types.ERROR_OBJ {
-		return nil, fmt.Errorf("runtime error: %s", result.Inspect())
+	eval := evaluator.NewWithLimits(ctx, s.Limits)
+	result := eval.ApplyFunction(fnObj, pebbleArgs)
+	s.stats = evalStats(eval)
+	if err := evalError("", result); err != nil {
+		return nil, err
 	}
 
 	return result, nil
 }
 
-// convertToMoxyObject converts standard Go types to Moxy objects.
-func convertToMoxyObject(val any) types.Object {
-	switch v := val.(type) {
-	case types.Object:
-		return v
-	case int:
-		return &types.Integer{Value: int64(v)}
-	case int64:
-		return &types.Integer{Value: v}
-	case float64:
-		return &types.Float{Value: v}
-	case string:
-		return &types.String{Value: v}
-	case bool:
-		if v {
-			return types.TRUE
-		}
-		return types.FALSE
-	case nil:
-		return types.NULL
-	case map[string]any:
-		pairs := make(map[types.HashKey]types.HashPair)
-		for k, val := range v {
-			key := &types.String{Value: k}
-			pVal := convertToMoxyObject(val)
-			pairs[key.HashKey()] = types.HashPair{Key: key, Value: pVal}
-		}
-		return &types.Hash{Pairs: pairs}
-	case []any:
-		elements := make([]types.Object, len(v))
-		for i, val := range v {
-			elements[i] = convertToMoxyObject(val)
+// Decode stores a script result in the Go value target points to, using the
+// same `moxy` struct tags as SetGlobal. See types.Decode for the rules.
+func Decode(obj types.Object, target any) error {
+	return types.Decode(obj, target)
+}
+
+// convertArgs converts the Go arguments of a call to Moxy objects.
+func convertArgs(args []any) ([]types.Object, error) {
+	converted := make([]types.Object, len(args))
+	for i, arg := range args {
+		obj, err := types.FromGo(arg)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %w", i, err)
 		}
-		return &types.Array{Elements: elements}
-	default:
-		return nil
+		converted[i] = obj
 	}
+	return converted, nil
 }
 
 // RunREPL starts an interactive REPL session.
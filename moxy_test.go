@@ -0,0 +1,618 @@
+package moxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pannagaperumal/moxy/types"
+)
+
+func TestContextInterrupts(t *testing.T) {
+	tests := []struct {
+		name     string
+		ctx      func() (context.Context, context.CancelFunc)
+		expected error
+	}{
+		{"deadline", func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 20*time.Millisecond)
+		}, context.DeadlineExceeded},
+		{"cancel", func() (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(20*time.Millisecond, cancel)
+			return ctx, cancel
+		}, context.Canceled},
+	}
+
+	for _, tt := range tests {
+		s := New()
+		if _, err := s.Run(`func spin() { for true {} }`); err != nil {
+			t.Fatalf("%s - unexpected error: %s", tt.name, err)
+		}
+
+		ctx, cancel := tt.ctx()
+		_, err := s.RunContext(ctx, `var n = 0; for true { n = n + 1 }`)
+		cancel()
+		checkInterrupted(t, tt.name+" RunContext", err, tt.expected)
+
+		ctx, cancel = tt.ctx()
+		_, err = s.RunVMContext(ctx, `var n = 0; for true { n = n + 1 }`)
+		cancel()
+		checkInterrupted(t, tt.name+" RunVMContext", err, tt.expected)
+
+		ctx, cancel = tt.ctx()
+		_, err = s.CallContext(ctx, "spin")
+		cancel()
+		checkInterrupted(t, tt.name+" CallContext", err, tt.expected)
+	}
+}
+
+func TestContextFinishedRun(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	input := `var n = 0
+for n < 5000 { n = n + 1 }
+n`
+	s := New()
+	result, err := s.RunContext(ctx, input)
+	if err != nil || result.Inspect() != "5000" {
+		t.Fatalf("RunContext - expected 5000, got=%v (%v)", result, err)
+	}
+	result, err = s.RunVMContext(ctx, input)
+	if err != nil || result.Inspect() != "5000" {
+		t.Fatalf("RunVMContext - expected 5000, got=%v (%v)", result, err)
+	}
+}
+
+func TestBuiltinsArePerState(t *testing.T) {
+	a, b := New(), New()
+	a.RegisterFunction("answer", func(args ...types.Object) types.Object {
+		return &types.Integer{Value: 42}
+	})
+
+	for _, run := range []func(string) (types.Object, error){a.Run, a.RunVM} {
+		result, err := run(`answer()`)
+		if err != nil || result.Inspect() != "42" {
+			t.Fatalf("expected 42 from the registering State, got=%v (%v)", result, err)
+		}
+	}
+	if _, err := b.Run(`answer()`); err == nil {
+		t.Fatalf("Run - another State's builtin leaked")
+	}
+	if _, err := b.RunVM(`answer()`); err == nil {
+		t.Fatalf("RunVM - another State's builtin leaked")
+	}
+}
+
+func TestManyBuiltins(t *testing.T) {
+	// Identifiers are letters only, so name the builtins fa, fb, ... fln.
+	name := func(i int) string { return fmt.Sprintf("f%c%c", 'a'+i/26, 'a'+i%26) }
+
+	s := New()
+	for i := range 300 {
+		n := int64(i)
+		s.RegisterFunction(name(i), func(args ...types.Object) types.Object {
+			return &types.Integer{Value: n}
+		})
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{name(0) + "()", "0"},
+		{name(255) + "()", "255"},
+		{name(256) + "()", "256"},
+		{name(299) + "()", "299"},
+	}
+
+	for i, tt := range tests {
+		result, err := s.Run(tt.input)
+		if err != nil || result.Inspect() != tt.expected {
+			t.Fatalf("tests[%d] Run - expected=%s, got=%v (%v)", i, tt.expected, result, err)
+		}
+		result, err = s.RunVM(tt.input)
+		if err != nil || result.Inspect() != tt.expected {
+			t.Fatalf("tests[%d] RunVM - expected=%s, got=%v (%v)", i, tt.expected, result, err)
+		}
+	}
+}
+
+// outcome is the inspected result of a run, or its error message.
+func outcome(result types.Object, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return result.Inspect()
+}
+
+// matches reports whether got is the expected result, or an error message
+// that reports expected.
+func matches(got, expected string) bool {
+	return got == expected || strings.Contains(got, ": "+expected)
+}
+
+func checkInterrupted(t *testing.T, name string, err, expected error) {
+	t.Helper()
+
+	var ierr *InterruptError
+	switch {
+	case !errors.As(err, &ierr):
+		t.Fatalf("%s - expected InterruptError, got=%v", name, err)
+	case !errors.Is(err, expected):
+		t.Fatalf("%s - expected %v, got=%v", name, expected, err)
+	case ierr.Location == "":
+		t.Fatalf("%s - InterruptError has no location", name)
+	}
+}
+
+func TestCallVM(t *testing.T) {
+	s := New()
+	if _, err := s.CallVM("add", 1, 2); err == nil {
+		t.Fatalf("CallVM before RunVM - expected an error")
+	}
+
+	if _, err := s.RunVM(`var add = func(a, b) { a + b }
+var makeAdder = func(x) { func(y) { x + y } }`); err != nil {
+		t.Fatalf("RunVM: %s", err)
+	}
+	// Globals from the first run are visible to the next one.
+	if _, err := s.RunVM(`var addTwo = makeAdder(2)
+var spin = func() { for true {} }
+var fail = func() { 1 + "a" }`); err != nil {
+		t.Fatalf("RunVM: %s", err)
+	}
+
+	tests := []struct {
+		name     string
+		args     []any
+		expected string
+	}{
+		{"add", []any{2, 3}, "5"},
+		{"add", []any{"a", "b"}, "ab"},
+		{"addTwo", []any{40}, "42"},
+	}
+
+	for i, tt := range tests {
+		result, err := s.CallVM(tt.name, tt.args...)
+		if err != nil {
+			t.Fatalf("tests[%d] - unexpected error: %s", i, err)
+		}
+		if result.Inspect() != tt.expected {
+			t.Fatalf("tests[%d] - expected=%s, got=%s", i, tt.expected, result.Inspect())
+		}
+	}
+
+	if _, err := s.CallVM("missing"); err == nil {
+		t.Fatalf("CallVM(missing) - expected an error")
+	}
+	if _, err := s.CallVM("fail"); err == nil {
+		t.Fatalf("CallVM(fail) - expected an error")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	_, err := s.CallVMContext(ctx, "spin")
+	cancel()
+	checkInterrupted(t, "CallVMContext", err, context.DeadlineExceeded)
+
+	// Failed and interrupted calls leave the VM ready for the next one.
+	result, err := s.CallVM("add", 1, 1)
+	if err != nil || result.Inspect() != "2" {
+		t.Fatalf("CallVM after an interrupted call - expected 2, got=%v (%v)", result, err)
+	}
+	if s.Stats().Instructions == 0 {
+		t.Fatalf("CallVM reported no instructions")
+	}
+}
+
+func TestRegisterGoFunc(t *testing.T) {
+	errDenied := errors.New("denied")
+
+	s := New()
+	if err := s.RegisterGoFunc("greet", func(name string, times int) string {
+		return fmt.Sprintf("%s x%d", name, times)
+	}); err != nil {
+		t.Fatalf("RegisterGoFunc: %s", err)
+	}
+	if err := s.RegisterGoFunc("check", func(n int) (bool, error) {
+		if n < 0 {
+			return false, errDenied
+		}
+		return true, nil
+	}); err != nil {
+		t.Fatalf("RegisterGoFunc: %s", err)
+	}
+	if err := s.RegisterGoFunc("explode", func() int { panic("boom") }); err != nil {
+		t.Fatalf("RegisterGoFunc: %s", err)
+	}
+	if err := s.RegisterGoFunc("bad", 42); err == nil {
+		t.Fatalf("RegisterGoFunc(42) - expected an error")
+	}
+
+	for _, run := range []func(string) (types.Object, error){s.Run, s.RunVM} {
+		result, err := run(`greet("ada", 2)`)
+		if err != nil || result.Inspect() != "ada x2" {
+			t.Fatalf("expected \"ada x2\", got=%v (%v)", result, err)
+		}
+		if _, err := run(`check(-1)`); !errors.Is(err, errDenied) {
+			t.Fatalf("expected errDenied, got=%v", err)
+		}
+		if _, err := run(`explode()`); err == nil || !strings.Contains(err.Error(), "explode: panic: boom") {
+			t.Fatalf("expected the recovered panic, got=%v", err)
+		}
+	}
+}
+
+type testCart struct {
+	Owner string `moxy:"owner"`
+	Items []string
+}
+
+func (c *testCart) Add(item string) int {
+	c.Items = append(c.Items, item)
+	return len(c.Items)
+}
+
+func TestHostObjectInScripts(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`cart["owner"]`, "ada"},
+		{`cart["Add"]("pen") > 0`, "true"},
+		{`var add = cart["Add"]; add("ink") > 1`, "true"},
+		{`cart == cart`, "true"},
+		{`cart == other`, "false"},
+	}
+
+	// Host objects reach the evaluator through SetGlobal and the VM through
+	// Program globals.
+	cart, other := &testCart{Owner: "ada"}, &testCart{}
+	s := New()
+	s.SetGlobal("cart", types.NewHostObject(cart))
+	s.SetGlobal("other", types.NewHostObject(other))
+	globals := map[string]any{"cart": types.NewHostObject(cart), "other": types.NewHostObject(other)}
+
+	for i, tt := range tests {
+		result, err := s.Run(tt.input)
+		if err != nil || result.Inspect() != tt.expected {
+			t.Fatalf("tests[%d] Run - expected=%s, got=%v (%v)", i, tt.expected, result, err)
+		}
+
+		prog, err := Compile(tt.input)
+		if err != nil {
+			t.Fatalf("tests[%d] - Compile: %s", i, err)
+		}
+		result, err = prog.Run(context.Background(), globals)
+		if err != nil || result.Inspect() != tt.expected {
+			t.Fatalf("tests[%d] Program - expected=%s, got=%v (%v)", i, tt.expected, result, err)
+		}
+	}
+
+	// Both engines called Add twice on the live Go value.
+	if len(cart.Items) != 4 {
+		t.Fatalf("expected 4 items, got=%v", cart.Items)
+	}
+}
+
+func TestSelectors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // a result, or the error message after the prefix
+	}{
+		{`var h = {"a": 1, "b": {"c": 2}}; h.a + h.b.c`, "3"},
+		{`var h = {"a": 1}; h.missing`, "null"},
+		{`var h = {"item2": 3}; h.item2`, "3"},
+		{`var h = {"a": 1}; h.a = 5; h.a`, "5"},
+		{`var h = {}; h.n = 1; h.n = h.n + 1; h.n`, "2"},
+		{`var h = {"f": func(x) { x * 2 }}; h.f(4)`, "8"},
+		{`var f = func(h) { h.v = h.v + 1 }; var h = {"v": 1}; f(h); h.v`, "2"},
+		{`var x = 5; x.y`, "type INTEGER has no property y"},
+		{`var h = {"a": 1}; h.b.c`, "type NULL has no property c"},
+		{`var x = 5; x.y = 1`, "cannot set property y on INTEGER"},
+	}
+
+	for i, tt := range tests {
+		for _, run := range []func(string) (types.Object, error){New().Run, New().RunVM} {
+			if got := outcome(run(tt.input)); !matches(got, tt.expected) {
+				t.Fatalf("tests[%d] - expected=%s, got=%s", i, tt.expected, got)
+			}
+		}
+	}
+
+	// Selectors on host objects read and write the Go value.
+	cart := &testCart{Owner: "ada"}
+	s := New()
+	s.SetGlobal("cart", types.NewHostObject(cart))
+	result, err := s.Run(`cart.owner = "bob"; cart.Add("pen"); cart.owner`)
+	if err != nil || result.Inspect() != "bob" {
+		t.Fatalf("Run - expected bob, got=%v (%v)", result, err)
+	}
+
+	prog, err := Compile(`cart.Add("ink"); cart.owner = cart.owner + "!"`)
+	if err != nil {
+		t.Fatalf("Compile: %s", err)
+	}
+	if _, err := prog.Run(context.Background(), map[string]any{"cart": types.NewHostObject(cart)}); err != nil {
+		t.Fatalf("Program.Run: %s", err)
+	}
+	if cart.Owner != "bob!" || len(cart.Items) != 2 {
+		t.Fatalf("expected owner bob! with 2 items, got=%+v", cart)
+	}
+}
+
+func TestIndexAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // a result, or the error message after the prefix
+	}{
+		{`var a = [1, 2]; var b = a; b[0] = 9; a`, "[9, 2]"},
+		{`var h = {"k": 1}; var g = h; g["k"] = 2; g["n"] = 3; h["k"] + h["n"]`, "5"},
+		{`var f = func(a) { a[1] = "x" }; var a = [1, 2]; f(a); a`, "[1, x]"},
+		{`var a = [[0]]; a[0][0] = 5; a`, "[[5]]"},
+		{`var a = [1]; a[0] = a; a`, "[[...]]"},
+		{`var h = {"k": 1}; h["self"] = h; h["self"]["self"]["k"]`, "1"},
+		{`var a = [1]; a[1] = 2`, "index out of range: 1 with length 1"},
+		{`var a = [1]; a[-1] = 2`, "index out of range: -1 with length 1"},
+		{`var h = {}; h[{}] = 2`, "unusable as hash key: HASH"},
+		{`var x = 1; x[0] = 2`, "index assignment not supported: INTEGER"},
+	}
+
+	for i, tt := range tests {
+		for _, run := range []func(string) (types.Object, error){New().Run, New().RunVM} {
+			if got := outcome(run(tt.input)); !matches(got, tt.expected) {
+				t.Fatalf("tests[%d] - expected=%s, got=%s", i, tt.expected, got)
+			}
+		}
+	}
+}
+
+// New keys are charged against MaxMemory even when the stored values are
+// scalars that cost nothing to build.
+func TestMemoryLimitOnAssignment(t *testing.T) {
+	tests := []string{
+		`var h = {}; for i := 0; i < 200000; i = i + 1 { h[i] = i }`,
+		`var a = [{}]; for i := 0; i < 200000; i = i + 1 { a[0][i] = true }`,
+		`var h = {"x": {}}; for i := 0; i < 200000; i = i + 1 { h.x[i] = i }`,
+	}
+
+	for i, input := range tests {
+		for _, engine := range []string{"Run", "RunVM"} {
+			s := New()
+			s.Limits = Limits{MaxMemory: 256 << 10}
+			run := s.Run
+			if engine == "RunVM" {
+				run = s.RunVM
+			}
+			if _, err := run(input); !errors.Is(err, ErrMemoryLimit) {
+				t.Fatalf("tests[%d] %s - expected ErrMemoryLimit, got=%v", i, engine, err)
+			}
+		}
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   string
+		line  string
+		caret string
+	}{
+		{`var x = "é"; var y = x + 1`, "1:24", `var x = "é"; var y = x + 1`, strings.Repeat(" ", 23) + "^"},
+		{"var a = 1;\n\tvar b = a + true;", "2:12", "\tvar b = a + true;", "\t          ^"},
+		{"var f = func(x) { x + \"s\" };\nf(1)", "1:21", `var f = func(x) { x + "s" };`, strings.Repeat(" ", 20) + "^"},
+		{`var a = ;`, "1:9", `var a = ;`, "        ^"},
+		{`foo(1)`, "1:1", `foo(1)`, "^"},
+	}
+
+	for i, tt := range tests {
+		for _, run := range []func(string) (types.Object, error){New().Run, New().RunVM} {
+			_, err := run(tt.input)
+			if err == nil {
+				t.Fatalf("tests[%d] - expected an error", i)
+			}
+			msg := err.Error()
+			if !strings.Contains(msg, tt.pos+": ") {
+				t.Fatalf("tests[%d] - expected position %s, got=%q", i, tt.pos, msg)
+			}
+			if !strings.HasSuffix(msg, "\n"+tt.line+"\n"+tt.caret) {
+				t.Fatalf("tests[%d] - expected caret %q, got=%q", i, tt.caret, msg)
+			}
+		}
+	}
+}
+
+func TestErrorTypes(t *testing.T) {
+	errHost := errors.New("host failed")
+
+	for _, engine := range []string{"Run", "RunVM"} {
+		run := func(input string) error {
+			s := New()
+			s.Limits = Limits{MaxInstructions: 10000}
+			s.RegisterFunction("fail", func(args ...types.Object) types.Object {
+				return &types.Error{Message: errHost.Error(), Err: errHost}
+			})
+			var err error
+			if engine == "Run" {
+				_, err = s.Run(input)
+			} else {
+				_, err = s.RunVM(input)
+			}
+			return err
+		}
+
+		var serr *SyntaxError
+		if err := run("var a = ;\nvar = 2;"); !errors.As(err, &serr) {
+			t.Fatalf("%s - expected a *SyntaxError, got=%T (%v)", engine, err, err)
+		}
+		if d := serr.Diagnostics; d[0].Pos != (Position{Line: 1, Column: 9}) || d[len(d)-1].Pos.Line != 2 {
+			t.Fatalf("%s - expected diagnostics on both lines, got=%v", engine, d)
+		}
+
+		var rerr *RuntimeError
+		err := run("func f(x) {\n  x + true\n}\nf(1)")
+		if !errors.As(err, &rerr) {
+			t.Fatalf("%s - expected a *RuntimeError, got=%T (%v)", engine, err, err)
+		}
+		if rerr.Pos != (Position{Line: 2, Column: 5}) {
+			t.Fatalf("%s - expected the error at 2:5, got=%s", engine, rerr.Pos)
+		}
+		if trace := rerr.StackTrace(); !strings.HasPrefix(trace, "at f (2:5)\nat <main> (4:") {
+			t.Fatalf("%s - wrong stack trace: %q", engine, trace)
+		}
+
+		err = run(`var x = 1; fail(x)`)
+		if !errors.As(err, &rerr) || !errors.Is(err, errHost) {
+			t.Fatalf("%s - expected a *RuntimeError wrapping the host error, got=%T (%v)", engine, err, err)
+		}
+
+		var lerr *LimitError
+		err = run(`for true {}`)
+		if !errors.As(err, &lerr) || !errors.Is(err, ErrBudgetExceeded) {
+			t.Fatalf("%s - expected a *LimitError for the budget, got=%T (%v)", engine, err, err)
+		}
+		if errors.As(err, &rerr) {
+			t.Fatalf("%s - a limit must not also be a *RuntimeError", engine)
+		}
+	}
+
+	// Only the VM compiles, so only it reports compile errors.
+	var cerr *CompileError
+	if _, err := New().RunVM("var a = 1;\nb + a"); !errors.As(err, &cerr) {
+		t.Fatalf("expected a *CompileError, got=%T (%v)", err, err)
+	}
+	if cerr.Pos != (Position{Line: 2, Column: 1}) || cerr.Message != "undefined variable b" {
+		t.Fatalf("wrong compile error: %s %q", cerr.Pos, cerr.Message)
+	}
+}
+
+func TestBreakContinue(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"var n = 0\nfor var i = 0; i < 10; i = i + 1 { if (i == 5) { break }; n = n + i }\nn", "10"},
+		{"var n = 0\nfor var i = 0; i < 10; i = i + 1 { if (i % 2 == 0) { continue }; n = n + i }\nn", "25"},
+		{"var i = 0\nwhile (true) { i = i + 1; if (i > 3) { break } }\ni", "4"},
+		{"var i = 0\nvar n = 0\nwhile (i < 5) { i = i + 1; if (i == 2) { continue }; n = n + i }\nn", "13"},
+		// A labeled break or continue applies to the loop it names.
+		{"var n = 0\nouter: for var i = 0; i < 3; i = i + 1 { for var j = 0; j < 3; j = j + 1 { if (j == 1) { continue outer }; if (i == 2) { break outer }; n = n + 1 } }\nn", "2"},
+		{"var f = func() { var s = 0; for var i = 0; i < 100; i = i + 1 { if (i == 4) { return s }; s = s + i } }\nf()", "6"},
+		// Nested loops must not leave values behind on the VM stack.
+		{"var c = 0\nfor var i = 0; i < 2000; i = i + 1 { for var j = 0; j < 10; j = j + 1 { c = c + 1 } }\nc", "20000"},
+	}
+
+	for i, tt := range tests {
+		for _, run := range []func(string) (types.Object, error){New().Run, New().RunVM} {
+			if got := outcome(run(tt.input)); got != tt.expected {
+				t.Fatalf("tests[%d] - expected=%s, got=%s", i, tt.expected, got)
+			}
+		}
+	}
+}
+
+func TestRangeLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // a result, or the error message after the prefix
+	}{
+		{"var s = 0\nfor i, v := range [1, 2, 3] { s = s + i * v }\ns", "8"},
+		{"var out = \"\"\nfor k, v := range {\"b\": 1, \"a\": 2, \"c\": 3} { out = out + k }\nout", "bac"},
+		{"var n = 0\nfor i := range \"héllo\" { n = n + i }\nn", "13"},
+		{"var n = 0\nfor range [1, 2, 3] { n = n + 1 }\nn", "3"},
+		{"var f = func() { var s = 0; for _, v := range [1, 2] { s = s + v }; s }\nf()", "3"},
+		{"var n = 0\nfor _, v := range [1, 2, 3, 4] { if (v == 2) { continue }; if (v == 4) { break }; n = n + v }\nn", "4"},
+		{"var n = 0\nouter: for _, a := range [1, 2] { for _, b := range [10, 20] { if (b == 20) { continue outer }; n = n + a * b } }\nn", "30"},
+		// The loop variables and the body's declarations end with the loop,
+		// and do not replace variables of the same name outside it.
+		{"for i, v := range [1] {}\ni", "identifier not found: i"},
+		{"for i, v := range [1] { var x = v }\nx", "identifier not found: x"},
+		{"var v = 5\nfor _, v := range [1] {}\nv", "5"},
+		{"for _, v := range 5 {}", "cannot range over INTEGER"},
+	}
+
+	for i, tt := range tests {
+		for _, run := range []func(string) (types.Object, error){New().Run, New().RunVM} {
+			got := outcome(run(tt.input))
+			// The VM rejects the undefined names when compiling.
+			got = strings.Replace(got, "undefined variable", "identifier not found:", 1)
+			if !matches(got, tt.expected) {
+				t.Fatalf("tests[%d] - expected=%s, got=%s", i, tt.expected, got)
+			}
+		}
+	}
+}
+
+func TestHashBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // a result, or the error message after the prefix
+	}{
+		{`var h = {"b": 1, "a": 2}; h["c"] = 3; h["b"] = 4; [keys(h), values(h)]`, `[[b, a, c], [4, 2, 3]]`},
+		{`keys({})`, "[]"},
+		{`[len({"a": 1, "b": 2}), len([1, 2, 3]), len("héllo")]`, "[2, 3, 6]"},
+		{`{"x": 1, 2: "y", true: 3}`, `{x: 1, 2: y, true: 3}`},
+		{`keys([1])`, "argument to `keys` must be HASH, got ARRAY"},
+		{`values(1, 2)`, "wrong number of arguments. got=2, want=1"},
+		{`len(1)`, "argument to `len` not supported, got INTEGER"},
+	}
+
+	for i, tt := range tests {
+		for _, run := range []func(string) (types.Object, error){New().Run, New().RunVM} {
+			if got := outcome(run(tt.input)); !matches(got, tt.expected) {
+				t.Fatalf("tests[%d] - expected=%s, got=%s", i, tt.expected, got)
+			}
+		}
+	}
+
+	// Adding builtins must not move the existing ones: compiled code refers
+	// to builtins by index.
+	for i, name := range []string{"len", "print"} {
+		if got := types.Builtins[i].Name; got != name {
+			t.Fatalf("builtin %d - expected=%s, got=%s", i, name, got)
+		}
+	}
+}
+
+func TestComparisons(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // a result, or the error message after the prefix
+	}{
+		{`[1, [2]] == [1, [2]]`, "true"},
+		{`{"a": 1, "b": 2} == {"b": 2, "a": 1}`, "true"},
+		{`{"a": 1} == {"a": 1, "b": 2}`, "false"},
+		{`[1 == "1", 1 != "1", true == true, "a" == "a"]`, "[false, true, true, true]"},
+		{`var f = func() { 1 }; [f == f, f == func() { 1 }]`, "[true, false]"},
+		{`9007199254740993 > 9007199254740992.0`, "true"},
+		{`9007199254740993 == 9007199254740992.0`, "false"},
+		{`9007199254740992 == 9007199254740992.0`, "true"},
+		{`[-2 < -1.5, -1 >= -1.5, 1 + 0.5]`, "[true, true, 1.5]"},
+		{`var n = 0.0 / 0.0; [n < 1, n > 1, 1 < n, 1 >= n, n == 1, 1 != n]`, "[false, false, false, false, false, true]"},
+		{`["a" < "b", "ab" <= "a", [1, 2.5] < [1, 3], [1] < [1, 0]]`, "[true, false, true, true]"},
+		{`sort([3, 1.5, 2])`, "[1.5, 2, 3]"},
+		{`sort(["b", "a", "c"])`, "[a, b, c]"},
+		{`var xs = [2, 1]; var ys = sort(xs); xs`, "[2, 1]"},
+		{`var k = [1, "a"]; var h = {}; h[k] = 1; k[0] = 2; [h[[1, "a"]], h[k]]`, "[1, null]"},
+		{`"a" < 1`, "cannot compare STRING with INTEGER"},
+		{`{} < {}`, "cannot compare HASH with HASH"},
+		{`true < false`, "cannot compare BOOLEAN with BOOLEAN"},
+		{`[1] < ["a"]`, "cannot compare INTEGER with STRING"},
+		{`sort([1, "a"])`, "cannot compare"},
+		{`1 << 2.0`, "unknown operator: INTEGER << FLOAT"},
+		{`1.0 >> 2.0`, "unknown operator: FLOAT >> FLOAT"},
+		{`"a" - 1`, "type mismatch: STRING - INTEGER"},
+	}
+
+	for i, tt := range tests {
+		for _, run := range []func(string) (types.Object, error){New().Run, New().RunVM} {
+			if got := outcome(run(tt.input)); !matches(got, tt.expected) {
+				t.Fatalf("tests[%d] - expected=%s, got=%s", i, tt.expected, got)
+			}
+		}
+	}
+}
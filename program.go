@@ -0,0 +1,123 @@
+package moxy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/pannagaperumal/moxy/internal/compiler"
+	"github.com/pannagaperumal/moxy/internal/lexer"
+	"github.com/pannagaperumal/moxy/internal/parser"
+	"github.com/pannagaperumal/moxy/internal/vm"
+	"github.com/pannagaperumal/moxy/types"
+)
+
+// Program is a script compiled once for the VM and run many times. It is
+// immutable and safe to run from many goroutines at once: every run gets its
+// own stack, frames and globals.
+type Program struct {
+	bytecode   *compiler.Bytecode
+	externals  map[string]int // host-supplied global name -> slot
+	numGlobals int
+	limits     Limits
+	src        string // for quoting the source line in errors
+}
+
+// Compile lexes, parses and compiles src against the default builtins.
+// Identifiers the script uses without defining become globals that must be
+// supplied to Run.
+func Compile(src string) (*Program, error) {
+	return compile(src, types.NewBuiltinTable(), Limits{})
+}
+
+// Compile compiles src like the package-level Compile, but against the
+// functions registered on s and bounded by s.Limits. The program keeps a
+// snapshot of the builtins, so functions registered later are not visible to
+// it. Globals the script defines in s are not; supply them to Run.
+func (s *State) Compile(src string) (*Program, error) {
+	return compile(src, s.builtins.Clone(), s.Limits)
+}
+
+func compile(src string, builtins *types.BuiltinTable, lim Limits) (*Program, error) {
+	l := lexer.New(src)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		return nil, syntaxError(src, p)
+	}
+
+	comp := compiler.NewWithBuiltins(builtins)
+	comp.DeclareExternalGlobals()
+	err := comp.Compile(program)
+	if err != nil {
+		return nil, compileError(src, err)
+	}
+
+	externals := make(map[string]int, len(comp.ExternalGlobals()))
+	for name, sym := range comp.ExternalGlobals() {
+		externals[name] = sym.Index
+	}
+
+	return &Program{
+		bytecode:   comp.Bytecode(),
+		externals:  externals,
+		numGlobals: comp.NumGlobals(),
+		limits:     lim,
+		src:        src,
+	}, nil
+}
+
+// WithLimits returns a copy of the program whose runs are bounded by limits.
+func (p *Program) WithLimits(limits Limits) *Program {
+	cp := *p
+	cp.limits = limits
+	return &cp
+}
+
+// Globals returns the sorted names of the globals the program expects the
+// host to supply.
+func (p *Program) Globals() []string {
+	names := make([]string, 0, len(p.externals))
+	for name := range p.externals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Run executes the program with a fresh VM. Every global the program
+// expects must be present in globals; extra entries are ignored.
+func (p *Program) Run(ctx context.Context, globals map[string]any) (types.Object, error) {
+	result, _, err := p.RunWithStats(ctx, globals)
+	return result, err
+}
+
+// RunWithStats is Run, but also reports the resources the run used.
+func (p *Program) RunWithStats(ctx context.Context, globals map[string]any) (types.Object, Stats, error) {
+	store := make([]types.Object, p.numGlobals)
+	for name, index := range p.externals {
+		value, ok := globals[name]
+		if !ok {
+			return nil, Stats{}, fmt.Errorf("missing global %s", name)
+		}
+		obj, err := types.FromGo(value)
+		if err != nil {
+			return nil, Stats{}, fmt.Errorf("global %s: %w", name, err)
+		}
+		store[index] = obj
+	}
+
+	machine := vm.NewWithGlobals(p.bytecode, store, p.limits)
+	err := machine.RunContext(ctx)
+	stats := vmStats(machine)
+	if err != nil {
+		return nil, stats, vmError(p.src, err)
+	}
+
+	result := machine.LastPoppedStackElem()
+	if result == nil {
+		return types.NULL, stats, nil
+	}
+	return result, stats, nil
+}
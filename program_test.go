@@ -0,0 +1,119 @@
+package moxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/pannagaperumal/moxy/types"
+)
+
+func TestProgramConcurrentRuns(t *testing.T) {
+	src := `var sum = n
+var add = func(x) { sum + x }
+for i := 0; i < 10; i = i + 1 { sum = add(i) }
+[sum, total > 100]`
+
+	prog, err := Compile(src)
+	if err != nil {
+		t.Fatalf("Compile: %s", err)
+	}
+	if got := fmt.Sprint(prog.Globals()); got != "[n total]" {
+		t.Fatalf("Globals - expected=[n total], got=%s", got)
+	}
+
+	// The evaluator gives the expected result for each input.
+	expected := make([]string, 20)
+	for i := range expected {
+		s := New()
+		if err := s.SetGlobal("n", i); err != nil {
+			t.Fatalf("SetGlobal: %s", err)
+		}
+		if err := s.SetGlobal("total", i*10); err != nil {
+			t.Fatalf("SetGlobal: %s", err)
+		}
+		result, err := s.Run(src)
+		if err != nil {
+			t.Fatalf("Run(%d): %s", i, err)
+		}
+		expected[i] = result.Inspect()
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 50 {
+				n := i % len(expected)
+				result, err := prog.Run(context.Background(), map[string]any{"n": n, "total": n * 10})
+				if err != nil {
+					errs <- err
+					return
+				}
+				if got := result.Inspect(); got != expected[n] {
+					errs <- fmt.Errorf("run %d - expected=%s, got=%s", n, expected[n], got)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+func TestStateCompile(t *testing.T) {
+	s := New()
+	s.RegisterFunction("double", func(args ...types.Object) types.Object {
+		return &types.Integer{Value: args[0].(*types.Integer).Value * 2}
+	})
+	s.Limits = Limits{MaxInstructions: 1000}
+
+	// Without the State, double is just another global the host must supply.
+	prog, err := Compile(`double(n)`)
+	if err != nil {
+		t.Fatalf("Compile: %s", err)
+	}
+	if got := fmt.Sprint(prog.Globals()); got != "[double n]" {
+		t.Fatalf("Compile Globals - expected=[double n], got=%s", got)
+	}
+
+	prog, err = s.Compile(`double(n)`)
+	if err != nil {
+		t.Fatalf("State.Compile: %s", err)
+	}
+	if got := fmt.Sprint(prog.Globals()); got != "[n]" {
+		t.Fatalf("State.Compile Globals - expected=[n], got=%s", got)
+	}
+
+	// Functions registered after compiling do not change the program.
+	s.RegisterFunction("double", func(args ...types.Object) types.Object {
+		return &types.Integer{Value: 0}
+	})
+
+	result, stats, err := prog.RunWithStats(context.Background(), map[string]any{"n": 21})
+	if err != nil {
+		t.Fatalf("RunWithStats: %s", err)
+	}
+	if result.Inspect() != "42" {
+		t.Fatalf("expected 42, got=%s", result.Inspect())
+	}
+	if stats.Instructions == 0 {
+		t.Fatalf("RunWithStats reported no instructions")
+	}
+
+	loop, err := s.Compile(`for true {}`)
+	if err != nil {
+		t.Fatalf("State.Compile: %s", err)
+	}
+	if _, err := loop.Run(context.Background(), nil); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected the State's Limits to apply, got=%v", err)
+	}
+}
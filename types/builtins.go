@@ -1,11 +1,18 @@
 package types
 
-import "fmt"
+import (
+	"fmt"
+	"slices"
+)
 
-var Builtins = []struct {
+// BuiltinDefinition names a builtin function.
+type BuiltinDefinition struct {
 	Name    string
 	Builtin *Builtin
-}{
+}
+
+// Builtins are the default builtins every BuiltinTable starts with.
+var Builtins = []BuiltinDefinition{
 	{
 		Name: "len",
 		Builtin: &Builtin{
@@ -19,7 +26,7 @@ var Builtins = []struct {
 				case *String:
 					return &Integer{Value: int64(len(arg.Value))}
 				case *Hash:
-					return &Integer{Value: int64(len(arg.Pairs))}
+					return &Integer{Value: int64(arg.Len())}
 				default:
 					return &Error{Message: fmt.Sprintf("argument to `len` not supported, got %s", args[0].Type())}
 				}
@@ -39,6 +46,66 @@ var Builtins = []struct {
 			},
 		},
 	},
+	// New builtins go last: compiled code refers to builtins by index.
+	{
+		Name:    "keys",
+		Builtin: hashBuiltin("keys", func(p HashPair) Object { return p.Key }),
+	},
+	{
+		Name:    "values",
+		Builtin: hashBuiltin("values", func(p HashPair) Object { return p.Value }),
+	},
+	{
+		Name: "sort",
+		Builtin: &Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return &Error{Message: fmt.Sprintf("wrong number of arguments. got=%d, want=1", len(args))}
+				}
+				arr, ok := args[0].(*Array)
+				if !ok {
+					return &Error{Message: fmt.Sprintf("argument to `sort` must be ARRAY, got %s", args[0].Type())}
+				}
+
+				// sort returns a sorted copy, in the order of Compare.
+				var err error
+				elements := slices.Clone(arr.Elements)
+				slices.SortStableFunc(elements, func(a, b Object) int {
+					c, cmpErr := Compare(a, b)
+					if cmpErr != nil && err == nil {
+						err = cmpErr
+					}
+					return c
+				})
+				if err != nil {
+					return &Error{Message: err.Error(), Err: err}
+				}
+				return &Array{Elements: elements}
+			},
+		},
+	},
+}
+
+// hashBuiltin returns a builtin called name that takes a hash and returns an
+// array of part of each pair, in insertion order.
+func hashBuiltin(name string, part func(HashPair) Object) *Builtin {
+	return &Builtin{
+		Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return &Error{Message: fmt.Sprintf("wrong number of arguments. got=%d, want=1", len(args))}
+			}
+			hash, ok := args[0].(*Hash)
+			if !ok {
+				return &Error{Message: fmt.Sprintf("argument to `%s` must be HASH, got %s", name, args[0].Type())}
+			}
+
+			elements := make([]Object, hash.Len())
+			for i, pair := range hash.Pairs() {
+				elements[i] = part(pair)
+			}
+			return &Array{Elements: elements}
+		},
+	}
 }
 
 func GetBuiltinByName(name string) *Builtin {
@@ -49,3 +116,68 @@ func GetBuiltinByName(name string) *Builtin {
 	}
 	return nil
 }
+
+// BuiltinTable is an ordered, per-interpreter set of builtins. Compiled
+// bytecode refers to builtins by their index in the table, so entries are
+// never removed or reordered; registering an existing name replaces the
+// function in place.
+type BuiltinTable struct {
+	definitions []BuiltinDefinition
+	index       map[string]int
+}
+
+// NewBuiltinTable returns a table holding the default Builtins.
+func NewBuiltinTable() *BuiltinTable {
+	t := &BuiltinTable{index: make(map[string]int)}
+	for _, def := range Builtins {
+		t.Register(def.Name, def.Builtin)
+	}
+	return t
+}
+
+// Register adds b under name and returns its index.
+func (t *BuiltinTable) Register(name string, b *Builtin) int {
+	if i, ok := t.index[name]; ok {
+		t.definitions[i].Builtin = b
+		return i
+	}
+
+	t.definitions = append(t.definitions, BuiltinDefinition{Name: name, Builtin: b})
+	t.index[name] = len(t.definitions) - 1
+	return len(t.definitions) - 1
+}
+
+// Clone returns a copy of the table that later Register calls on either
+// table do not affect.
+func (t *BuiltinTable) Clone() *BuiltinTable {
+	cp := &BuiltinTable{
+		definitions: append([]BuiltinDefinition(nil), t.definitions...),
+		index:       make(map[string]int, len(t.index)),
+	}
+	for name, i := range t.index {
+		cp.index[name] = i
+	}
+	return cp
+}
+
+// Lookup returns the builtin registered under name.
+func (t *BuiltinTable) Lookup(name string) (*Builtin, bool) {
+	i, ok := t.index[name]
+	if !ok {
+		return nil, false
+	}
+	return t.definitions[i].Builtin, true
+}
+
+// At returns the builtin at index i, or nil if there is none.
+func (t *BuiltinTable) At(i int) *Builtin {
+	if i < 0 || i >= len(t.definitions) {
+		return nil
+	}
+	return t.definitions[i].Builtin
+}
+
+// Definitions returns the registered builtins in index order.
+func (t *BuiltinTable) Definitions() []BuiltinDefinition {
+	return t.definitions
+}
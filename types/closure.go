@@ -1,9 +1,35 @@
 package types
 
 type Closure struct {
-	Fn            *CompiledFunction
-	FreeVariables []Object
+	Fn       *CompiledFunction
+	Upvalues []*Upvalue
 }
 
-func (c *Closure) Type() ObjectType { return "CLOSURE" }
+func (c *Closure) Type() ObjectType { return CLOSURE_OBJ }
 func (c *Closure) Inspect() string  { return "Closure" }
+
+// Upvalue is a variable captured by a closure. While the function that
+// declared the variable is running, Location points at its stack slot, so
+// the function and every closure over it share one variable. When that
+// function returns the VM closes the upvalue: the value moves into Closed and
+// Location points there instead.
+type Upvalue struct {
+	Location *Object
+	Closed   Object
+	Slot     int // stack slot while open
+}
+
+func (u *Upvalue) Type() ObjectType { return "UPVALUE" }
+func (u *Upvalue) Inspect() string  { return "Upvalue" }
+
+// Get returns the current value of the captured variable.
+func (u *Upvalue) Get() Object { return *u.Location }
+
+// Set assigns the captured variable.
+func (u *Upvalue) Set(obj Object) { *u.Location = obj }
+
+// Close detaches the upvalue from the stack, keeping its current value.
+func (u *Upvalue) Close() {
+	u.Closed = *u.Location
+	u.Location = &u.Closed
+}
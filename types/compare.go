@@ -0,0 +1,201 @@
+package types
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Equal reports whether a and b are the same value. Numbers are equal when
+// they are numerically equal, so 1 == 1.0; an integer and a float are
+// compared exactly, without rounding the integer to a float. Arrays are equal when they have
+// equal elements in the same order, and hashes when they have the same keys
+// mapped to equal values, in any order; an array or hash is always equal to
+// itself. Values of other types, such as functions, are equal only to
+// themselves.
+func Equal(a, b Object) bool {
+	return equal(a, b, nil)
+}
+
+// visited records the pairs of arrays and hashes being compared, so that a
+// collection that contains itself does not recurse forever.
+type visited map[[2]Object]bool
+
+// enter reports whether a and b are already being compared, and marks them
+// as being compared if not.
+func (v *visited) enter(a, b Object) bool {
+	if *v == nil {
+		*v = make(visited)
+	}
+	key := [2]Object{a, b}
+	if (*v)[key] {
+		return true
+	}
+	(*v)[key] = true
+	return false
+}
+
+func equal(a, b Object, seen visited) bool {
+	switch a := a.(type) {
+	case *Integer:
+		switch b := b.(type) {
+		case *Integer:
+			return a.Value == b.Value
+		case *Float:
+			return !math.IsNaN(b.Value) && compareIntFloat(a.Value, b.Value) == 0
+		}
+	case *Float:
+		switch b := b.(type) {
+		case *Integer:
+			return !math.IsNaN(a.Value) && compareIntFloat(b.Value, a.Value) == 0
+		case *Float:
+			return a.Value == b.Value
+		}
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+	case *Null:
+		_, ok := b.(*Null)
+		return ok
+	case *Array:
+		b, ok := b.(*Array)
+		if !ok || len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		if a == b || seen.enter(a, b) {
+			return true
+		}
+		for i := range a.Elements {
+			if !equal(a.Elements[i], b.Elements[i], seen) {
+				return false
+			}
+		}
+		return true
+	case *Hash:
+		b, ok := b.(*Hash)
+		if !ok || a.Len() != b.Len() {
+			return false
+		}
+		if a == b || seen.enter(a, b) {
+			return true
+		}
+		for _, pair := range a.pairs {
+			value, ok := b.Get(pair.Key)
+			if !ok || !equal(pair.Value, value, seen) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+// Compare returns -1, 0 or +1 as a is less than, equal to or greater than b.
+// Numbers are ordered numerically and exactly, with NaN before every other
+// number, as in cmp.Compare. Strings are ordered byte-wise, and arrays element by
+// element, a shorter array coming first when it is a prefix of the longer
+// one. Any other pair of values, such as a string and a number, is not
+// ordered and returns an error.
+func Compare(a, b Object) (int, error) {
+	return compare(a, b, nil)
+}
+
+func compare(a, b Object, seen visited) (int, error) {
+	switch a := a.(type) {
+	case *Integer:
+		switch b := b.(type) {
+		case *Integer:
+			return cmp.Compare(a.Value, b.Value), nil
+		case *Float:
+			if math.IsNaN(b.Value) {
+				return +1, nil
+			}
+			return compareIntFloat(a.Value, b.Value), nil
+		}
+	case *Float:
+		switch b := b.(type) {
+		case *Integer:
+			if math.IsNaN(a.Value) {
+				return -1, nil
+			}
+			return -compareIntFloat(b.Value, a.Value), nil
+		case *Float:
+			return cmp.Compare(a.Value, b.Value), nil
+		}
+	case *String:
+		if b, ok := b.(*String); ok {
+			return strings.Compare(a.Value, b.Value), nil
+		}
+	case *Array:
+		b, ok := b.(*Array)
+		if !ok {
+			break
+		}
+		if seen.enter(a, b) {
+			return 0, nil
+		}
+		for i := 0; i < len(a.Elements) && i < len(b.Elements); i++ {
+			c, err := compare(a.Elements[i], b.Elements[i], seen)
+			if err != nil || c != 0 {
+				return c, err
+			}
+		}
+		return cmp.Compare(len(a.Elements), len(b.Elements)), nil
+	}
+	return 0, fmt.Errorf("cannot compare %s with %s", a.Type(), b.Type())
+}
+
+// Order applies the ordering operator op, one of <, >, <= and >=, to a and b.
+// Operands are ordered as by Compare, except that a comparison involving a
+// NaN float is false, as in Go. Operands Compare cannot order return its
+// error, so every engine reports the same message for them.
+func Order(op string, a, b Object) (bool, error) {
+	if isNaN(a) || isNaN(b) {
+		return false, nil
+	}
+
+	c, err := Compare(a, b)
+	if err != nil {
+		return false, err
+	}
+
+	switch op {
+	case "<":
+		return c < 0, nil
+	case ">":
+		return c > 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">=":
+		return c >= 0, nil
+	default:
+		return false, fmt.Errorf("unknown ordering operator: %s", op)
+	}
+}
+
+func isNaN(obj Object) bool {
+	f, ok := obj.(*Float)
+	return ok && math.IsNaN(f.Value)
+}
+
+// compareIntFloat compares i with f exactly; f must not be NaN. Converting i
+// to float64 would round integers beyond 2^53, so 2^53+1 would equal 2^53.
+func compareIntFloat(i int64, f float64) int {
+	switch {
+	case f >= math.MaxInt64: // 2^63, the first float above every int64
+		return -1
+	case f < math.MinInt64:
+		return +1
+	}
+
+	whole := math.Trunc(f)
+	if c := cmp.Compare(i, int64(whole)); c != 0 {
+		return c
+	}
+	// i equals the integer part of f, so the fraction decides.
+	return cmp.Compare(0, f-whole)
+}
@@ -0,0 +1,57 @@
+package types
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	i := func(v int64) Object { return &Integer{Value: v} }
+	f := func(v float64) Object { return &Float{Value: v} }
+	s := func(v string) Object { return &String{Value: v} }
+	a := func(elements ...Object) Object { return &Array{Elements: elements} }
+
+	tests := []struct {
+		a, b     Object
+		expected int
+	}{
+		{i(1), i(2), -1},
+		{i(1 << 53), f(1 << 53), 0},
+		{i(1<<53 + 1), f(1 << 53), 1},
+		{f(-0.5), i(0), -1},
+		{i(math.MaxInt64), f(math.MaxInt64), -1}, // the float is 2^63
+		{f(math.NaN()), f(0), -1},
+		{f(math.NaN()), f(math.NaN()), 0},
+		{s("a"), s("b"), -1},
+		{s("b"), s("ab"), 1},
+		{a(i(1), i(2)), a(i(1), f(2.5)), -1},
+		{a(i(1)), a(i(1), i(0)), -1},
+		{a(), a(), 0},
+	}
+
+	for _, tt := range tests {
+		got, err := Compare(tt.a, tt.b)
+		if err != nil {
+			t.Fatalf("Compare(%s, %s): %s", tt.a.Inspect(), tt.b.Inspect(), err)
+		}
+		if got != tt.expected {
+			t.Fatalf("Compare(%s, %s) - expected=%d, got=%d", tt.a.Inspect(), tt.b.Inspect(), tt.expected, got)
+		}
+		if back, _ := Compare(tt.b, tt.a); back != -tt.expected {
+			t.Fatalf("Compare(%s, %s) - expected=%d, got=%d", tt.b.Inspect(), tt.a.Inspect(), -tt.expected, back)
+		}
+	}
+
+	for _, pair := range [][2]Object{{s("a"), i(1)}, {TRUE, FALSE}, {NewHash(0), NewHash(0)}, {a(i(1)), a(s("a"))}} {
+		if _, err := Compare(pair[0], pair[1]); err == nil {
+			t.Fatalf("Compare(%s, %s) - expected an error", pair[0].Inspect(), pair[1].Inspect())
+		}
+	}
+
+	// Ordering operators treat NaN as unordered, unlike Compare.
+	for _, op := range []string{"<", ">", "<=", ">="} {
+		if ok, err := Order(op, f(math.NaN()), i(1)); ok || err != nil {
+			t.Fatalf("Order(%s) with NaN - expected false, got=%t (%v)", op, ok, err)
+		}
+	}
+}
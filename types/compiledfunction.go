@@ -2,9 +2,11 @@ package types
 
 // CompiledFunction represents a compiled function in the VM
 type CompiledFunction struct {
+	Name          string // declared name, or "" for a function literal
 	Instructions  []byte
 	NumLocals     int
 	NumParameters int
+	Positions     PositionTable // source position of each instruction
 }
 
 // Type returns the type of the object
@@ -12,3 +14,11 @@ func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
 
 // Inspect returns a string representation of the compiled function
 func (cf *CompiledFunction) Inspect() string { return "CompiledFunction" }
+
+// DisplayName returns the name to show for the function in stack traces.
+func (cf *CompiledFunction) DisplayName() string {
+	if cf.Name == "" {
+		return AnonymousFunctionName
+	}
+	return cf.Name
+}
@@ -0,0 +1,313 @@
+package types
+
+import (
+	"cmp"
+	"encoding"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxConvertDepth bounds how deeply nested a Go value may be. It turns
+// pointer cycles into an error instead of a stack overflow.
+const maxConvertDepth = 100
+
+// ConversionError reports a value that could not be converted between Go and
+// Moxy, with the path to the offending element, e.g. value.Items[2].
+type ConversionError struct {
+	Path    string
+	Message string
+}
+
+func (e *ConversionError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+var (
+	objectType        = reflect.TypeOf((*Object)(nil)).Elem()
+	timeType          = reflect.TypeOf(time.Time{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// FromGo converts a Go value to a Moxy object.
+//
+// Scalars map to INTEGER, FLOAT, STRING and BOOLEAN; nil and nil pointers to
+// NULL. Slices and arrays become ARRAY, and maps with string or integer keys
+// become HASH. Structs become a HASH of their exported fields, named by the
+// field name or a `moxy:"name,omitempty"` tag; a tag of "-" skips the field
+// and embedded structs are flattened. time.Time becomes an RFC 3339 string,
+// other encoding.TextMarshaler values their text, and []byte a string.
+// Values that already are Moxy objects are returned unchanged.
+func FromGo(v any) (Object, error) {
+	switch v := v.(type) {
+	case Object:
+		return v, nil
+	case nil:
+		return NULL, nil
+	case bool:
+		return nativeBool(v), nil
+	case int:
+		return &Integer{Value: int64(v)}, nil
+	case int64:
+		return &Integer{Value: v}, nil
+	case float64:
+		return &Float{Value: v}, nil
+	case string:
+		return &String{Value: v}, nil
+	}
+
+	return fromGoValue(reflect.ValueOf(v), "value", 0)
+}
+
+func fromGoValue(v reflect.Value, path string, depth int) (Object, error) {
+	if depth > maxConvertDepth {
+		return nil, &ConversionError{Path: path, Message: "value is nested too deeply (cyclic?)"}
+	}
+
+	if !v.IsValid() {
+		return NULL, nil
+	}
+
+	t := v.Type()
+	switch {
+	case t.Implements(objectType):
+		if (t.Kind() == reflect.Pointer || t.Kind() == reflect.Interface) && v.IsNil() {
+			return NULL, nil
+		}
+		return v.Interface().(Object), nil
+	case t == timeType:
+		return &String{Value: v.Interface().(time.Time).Format(time.RFC3339Nano)}, nil
+	case t.Implements(textMarshalerType):
+		if t.Kind() == reflect.Pointer && v.IsNil() {
+			return NULL, nil
+		}
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return nil, &ConversionError{Path: path, Message: err.Error()}
+		}
+		return &String{Value: string(text)}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return nativeBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Integer{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := v.Uint()
+		if u > math.MaxInt64 {
+			return nil, &ConversionError{Path: path, Message: fmt.Sprintf("%d overflows INTEGER", u)}
+		}
+		return &Integer{Value: int64(u)}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Float{Value: v.Float()}, nil
+	case reflect.String:
+		return &String{Value: v.String()}, nil
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return NULL, nil
+		}
+		return fromGoValue(v.Elem(), path, depth+1)
+	case reflect.Slice:
+		if v.IsNil() {
+			return NULL, nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &String{Value: string(v.Bytes())}, nil
+		}
+		return fromGoSequence(v, path, depth)
+	case reflect.Array:
+		return fromGoSequence(v, path, depth)
+	case reflect.Map:
+		if v.IsNil() {
+			return NULL, nil
+		}
+		return fromGoMap(v, path, depth)
+	case reflect.Struct:
+		return fromGoStruct(v, path, depth)
+	default:
+		return nil, &ConversionError{Path: path, Message: "unsupported type " + t.String()}
+	}
+}
+
+func fromGoSequence(v reflect.Value, path string, depth int) (Object, error) {
+	elements := make([]Object, v.Len())
+	for i := range elements {
+		elem, err := fromGoValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), depth+1)
+		if err != nil {
+			return nil, err
+		}
+		elements[i] = elem
+	}
+	return &Array{Elements: elements}, nil
+}
+
+// fromGoMap converts a map with string or integer keys. Go maps have no
+// order, so the keys are added to the hash sorted, as fmt prints them.
+func fromGoMap(v reflect.Value, path string, depth int) (Object, error) {
+	hash := NewHash(v.Len())
+
+	keys := v.MapKeys()
+	slices.SortFunc(keys, compareMapKeys)
+
+	for _, k := range keys {
+		var key Object
+		switch k.Kind() {
+		case reflect.String:
+			key = &String{Value: k.String()}
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			key = &Integer{Value: k.Int()}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			u := k.Uint()
+			if u > math.MaxInt64 {
+				return nil, &ConversionError{Path: path, Message: fmt.Sprintf("map key %d overflows INTEGER", u)}
+			}
+			key = &Integer{Value: int64(u)}
+		default:
+			return nil, &ConversionError{Path: path, Message: "unsupported map key type " + k.Type().String()}
+		}
+
+		value, err := fromGoValue(v.MapIndex(k), fmt.Sprintf("%s[%s]", path, key.Inspect()), depth+1)
+		if err != nil {
+			return nil, err
+		}
+		hash.Set(key, value)
+	}
+
+	return hash, nil
+}
+
+// compareMapKeys orders map keys of one kind; keys of other kinds are left
+// for fromGoMap to reject.
+func compareMapKeys(a, b reflect.Value) int {
+	switch a.Kind() {
+	case reflect.String:
+		return cmp.Compare(a.String(), b.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cmp.Compare(a.Int(), b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cmp.Compare(a.Uint(), b.Uint())
+	default:
+		return 0
+	}
+}
+
+func fromGoStruct(v reflect.Value, path string, depth int) (Object, error) {
+	fields := structFields(v.Type())
+	hash := NewHash(len(fields))
+
+	for _, f := range fields {
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok || (f.omitEmpty && fv.IsZero()) {
+			continue
+		}
+
+		value, err := fromGoValue(fv, path+"."+f.name, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		hash.Set(&String{Value: f.name}, value)
+	}
+
+	return hash, nil
+}
+
+// fieldByIndex is like reflect.Value.FieldByIndex but reports false instead
+// of panicking when it meets a nil embedded pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// structField describes how a Go struct field appears to scripts.
+type structField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+var structFieldCache sync.Map // reflect.Type -> []structField
+
+// structFields lists the exported fields of t, honoring `moxy` tags and
+// flattening untagged embedded structs the way encoding/json does. Fields of
+// the outer struct win over promoted fields with the same name.
+func structFields(t reflect.Type) []structField {
+	if cached, ok := structFieldCache.Load(t); ok {
+		return cached.([]structField)
+	}
+
+	var fields []structField
+	seen := make(map[string]bool)
+	collectFields(t, nil, seen, &fields)
+
+	structFieldCache.Store(t, fields)
+	return fields
+}
+
+func collectFields(t reflect.Type, index []int, seen map[string]bool, fields *[]structField) {
+	var embedded []reflect.StructField
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("moxy")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, sf)
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		*fields = append(*fields, structField{
+			name:      name,
+			index:     append(append([]int(nil), index...), i),
+			omitEmpty: opts == "omitempty",
+		})
+	}
+
+	for _, sf := range embedded {
+		ft := sf.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		collectFields(ft, append(append([]int(nil), index...), sf.Index...), seen, fields)
+	}
+}
+
+func nativeBool(b bool) *Boolean {
+	if b {
+		return TRUE
+	}
+	return FALSE
+}
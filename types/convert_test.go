@@ -0,0 +1,140 @@
+package types
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+type convertBase struct {
+	ID   int
+	Name string
+}
+
+type convertUser struct {
+	convertBase
+	Name    string  `moxy:"name"`
+	Email   string  `moxy:"email,omitempty"`
+	Secret  string  `moxy:"-"`
+	Manager *string `moxy:"manager"`
+	age     int
+}
+
+type convertShadow struct {
+	convertBase
+	ID string // wins over the promoted convertBase.ID
+}
+
+type convertEmbeddedPointer struct {
+	*convertBase
+	Role string
+}
+
+type level string
+
+func (l level) MarshalText() ([]byte, error) { return []byte(strings.ToUpper(string(l))), nil }
+
+func TestFromGo(t *testing.T) {
+	when := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		input    any
+		expected string
+	}{
+		{"nil", nil, "null"},
+		{"int8", int8(-3), "-3"},
+		{"uint16", uint16(7), "7"},
+		{"float32", float32(1.5), "1.5"},
+		{"bytes", []byte("raw"), "raw"},
+		{"nil pointer", (*int)(nil), "null"},
+		{"nil slice", []int(nil), "null"},
+		{"nil map", map[string]int(nil), "null"},
+		{"pointer", func() *int { n := 4; return &n }(), "4"},
+		{"array", [2]string{"a", "b"}, "[a, b]"},
+		{"nested slice", []any{1, []int{2, 3}, nil}, "[1, [2, 3], null]"},
+		{"string keys", map[string]int{"b": 2, "a": 1}, "{a: 1, b: 2}"},
+		{"int keys", map[int8]bool{1: true}, "{1: true}"},
+		{"uint keys", map[uint]string{2: "two"}, "{2: two}"},
+		{"time", when, "2026-01-02T03:04:05Z"},
+		{"text marshaler", level("warn"), "WARN"},
+		{"tags and omitempty", convertUser{
+			convertBase: convertBase{ID: 7, Name: "base"},
+			Name:        "ada",
+			Secret:      "hidden",
+			age:         36,
+		}, "{ID: 7, Name: base, manager: null, name: ada}"},
+		{"omitempty set", convertUser{Email: "a@b.c"}, "{ID: 0, Name: , email: a@b.c, manager: null, name: }"},
+		{"shadowed field", convertShadow{convertBase{ID: 1, Name: "n"}, "outer"}, "{ID: outer, Name: n}"},
+		{"embedded pointer", convertEmbeddedPointer{convertBase: &convertBase{ID: 1, Name: "n"}, Role: "r"}, "{ID: 1, Name: n, Role: r}"},
+		{"nil embedded pointer", convertEmbeddedPointer{Role: "r"}, "{Role: r}"},
+		{"moxy object", &Integer{Value: 9}, "9"},
+	}
+
+	for _, tt := range tests {
+		obj, err := FromGo(tt.input)
+		if err != nil {
+			t.Fatalf("%s - unexpected error: %s", tt.name, err)
+		}
+		if got := inspectSorted(obj); got != tt.expected {
+			t.Fatalf("%s - expected=%s, got=%s", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestFromGoErrors(t *testing.T) {
+	type cyclic struct{ Next *cyclic }
+	loop := &cyclic{}
+	loop.Next = loop
+
+	tests := []struct {
+		name  string
+		input any
+		path  string
+	}{
+		{"uint overflow", uint64(math.MaxUint64), "value"},
+		{"uint key overflow", map[uint64]int{math.MaxUint64: 1}, "value"},
+		{"unsupported key", map[float64]int{1.5: 1}, "value"},
+		{"channel", make(chan int), "value"},
+		{"func field", struct{ F func() }{}, "value.F"},
+		{"nested", map[string][]any{"xs": {1, complex(1, 2)}}, "value[xs][1]"},
+		{"cycle", loop, "value.Next.Next"},
+	}
+
+	for _, tt := range tests {
+		_, err := FromGo(tt.input)
+
+		var cerr *ConversionError
+		if !errors.As(err, &cerr) {
+			t.Fatalf("%s - expected ConversionError, got=%v", tt.name, err)
+		}
+		if !strings.HasPrefix(cerr.Path, tt.path) {
+			t.Fatalf("%s - expected path %s, got=%s", tt.name, tt.path, cerr.Path)
+		}
+	}
+}
+
+// inspectSorted is Inspect with hash pairs sorted, so results do not depend
+// on map order.
+func inspectSorted(obj Object) string {
+	switch obj := obj.(type) {
+	case *Array:
+		elements := make([]string, len(obj.Elements))
+		for i, e := range obj.Elements {
+			elements[i] = inspectSorted(e)
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	case *Hash:
+		pairs := make([]string, 0, obj.Len())
+		for _, pair := range obj.Pairs() {
+			pairs = append(pairs, inspectSorted(pair.Key)+": "+inspectSorted(pair.Value))
+		}
+		sort.Strings(pairs)
+		return "{" + strings.Join(pairs, ", ") + "}"
+	default:
+		return obj.Inspect()
+	}
+}
@@ -0,0 +1,299 @@
+package types
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// Decode stores obj in the Go value target points to. It is the inverse of
+// FromGo and follows the same `moxy` tag conventions: HASH fills structs and
+// maps, ARRAY fills slices and arrays, and scalars fill the matching Go kinds.
+// Hash keys without a matching struct field are ignored. Decoding into an
+// interface{} produces int64, float64, string, bool, nil, []any and
+// map[string]any. Mismatches are reported as a *ConversionError whose path
+// starts at "result", e.g. result.items[3].price.
+func Decode(obj Object, target any) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("decode target must be a non-nil pointer, got %T", target)
+	}
+	if obj == nil {
+		obj = NULL
+	}
+	return decodeValue(obj, rv.Elem(), "result", 0)
+}
+
+func decodeValue(obj Object, v reflect.Value, path string, depth int) error {
+	if depth > maxConvertDepth {
+		return &ConversionError{Path: path, Message: "value is nested too deeply (cyclic?)"}
+	}
+
+	t := v.Type()
+
+	switch {
+	case objectType.AssignableTo(t) && t.Kind() == reflect.Interface && t.NumMethod() > 0:
+		v.Set(reflect.ValueOf(obj))
+		return nil
+	case t.Kind() == reflect.Interface && t.NumMethod() == 0:
+		value, err := toGo(obj, path, depth)
+		if err != nil {
+			return err
+		}
+		if value != nil {
+			v.Set(reflect.ValueOf(value))
+		} else {
+			v.Set(reflect.Zero(t))
+		}
+		return nil
+	case reflect.TypeOf(obj).AssignableTo(t):
+		v.Set(reflect.ValueOf(obj))
+		return nil
+	}
+
+	if obj.Type() == NULL_OBJ {
+		v.Set(reflect.Zero(t))
+		return nil
+	}
+
+	if t.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(t.Elem()))
+		}
+		return decodeValue(obj, v.Elem(), path, depth+1)
+	}
+
+	if t == timeType {
+		s, ok := obj.(*String)
+		if !ok {
+			return mismatch(path, "string", obj)
+		}
+		tm, err := time.Parse(time.RFC3339Nano, s.Value)
+		if err != nil {
+			return &ConversionError{Path: path, Message: err.Error()}
+		}
+		v.Set(reflect.ValueOf(tm))
+		return nil
+	}
+
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) && v.CanAddr() {
+		s, ok := obj.(*String)
+		if !ok {
+			return mismatch(path, "string", obj)
+		}
+		if err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s.Value)); err != nil {
+			return &ConversionError{Path: path, Message: err.Error()}
+		}
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		b, ok := obj.(*Boolean)
+		if !ok {
+			return mismatch(path, "boolean", obj)
+		}
+		v.SetBool(b.Value)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := obj.(*Integer)
+		if !ok {
+			return mismatch(path, "integer", obj)
+		}
+		if v.OverflowInt(i.Value) {
+			return &ConversionError{Path: path, Message: fmt.Sprintf("%d overflows %s", i.Value, t)}
+		}
+		v.SetInt(i.Value)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, ok := obj.(*Integer)
+		if !ok {
+			return mismatch(path, "integer", obj)
+		}
+		if i.Value < 0 || v.OverflowUint(uint64(i.Value)) {
+			return &ConversionError{Path: path, Message: fmt.Sprintf("%d overflows %s", i.Value, t)}
+		}
+		v.SetUint(uint64(i.Value))
+
+	case reflect.Float32, reflect.Float64:
+		switch n := obj.(type) {
+		case *Integer:
+			v.SetFloat(float64(n.Value))
+		case *Float:
+			v.SetFloat(n.Value)
+		default:
+			return mismatch(path, "number", obj)
+		}
+
+	case reflect.String:
+		s, ok := obj.(*String)
+		if !ok {
+			return mismatch(path, "string", obj)
+		}
+		v.SetString(s.Value)
+
+	case reflect.Slice:
+		if s, ok := obj.(*String); ok && t.Elem().Kind() == reflect.Uint8 {
+			v.SetBytes([]byte(s.Value))
+			return nil
+		}
+		arr, ok := obj.(*Array)
+		if !ok {
+			return mismatch(path, "array", obj)
+		}
+		slice := reflect.MakeSlice(t, len(arr.Elements), len(arr.Elements))
+		for i, elem := range arr.Elements {
+			if err := decodeValue(elem, slice.Index(i), fmt.Sprintf("%s[%d]", path, i), depth+1); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+
+	case reflect.Array:
+		arr, ok := obj.(*Array)
+		if !ok {
+			return mismatch(path, "array", obj)
+		}
+		if len(arr.Elements) > v.Len() {
+			return &ConversionError{Path: path, Message: fmt.Sprintf("%d elements do not fit in %s", len(arr.Elements), t)}
+		}
+		v.Set(reflect.Zero(t))
+		for i, elem := range arr.Elements {
+			if err := decodeValue(elem, v.Index(i), fmt.Sprintf("%s[%d]", path, i), depth+1); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		hash, ok := obj.(*Hash)
+		if !ok {
+			return mismatch(path, "hash", obj)
+		}
+		return decodeMap(hash, v, path, depth)
+
+	case reflect.Struct:
+		hash, ok := obj.(*Hash)
+		if !ok {
+			return mismatch(path, "hash", obj)
+		}
+		return decodeStruct(hash, v, path, depth)
+
+	default:
+		return &ConversionError{Path: path, Message: "cannot decode into " + t.String()}
+	}
+
+	return nil
+}
+
+func decodeMap(hash *Hash, v reflect.Value, path string, depth int) error {
+	t := v.Type()
+	m := reflect.MakeMapWithSize(t, hash.Len())
+
+	for _, pair := range hash.Pairs() {
+		elemPath := fmt.Sprintf("%s[%s]", path, pair.Key.Inspect())
+
+		key := reflect.New(t.Key()).Elem()
+		if err := decodeMapKey(pair.Key, key, elemPath, depth+1); err != nil {
+			return err
+		}
+
+		value := reflect.New(t.Elem()).Elem()
+		if err := decodeValue(pair.Value, value, elemPath, depth+1); err != nil {
+			return err
+		}
+		m.SetMapIndex(key, value)
+	}
+
+	v.Set(m)
+	return nil
+}
+
+// decodeMapKey is decodeValue for map keys. Integer keys are also accepted for
+// string-keyed maps, formatted in base 10.
+func decodeMapKey(obj Object, key reflect.Value, path string, depth int) error {
+	if i, ok := obj.(*Integer); ok && key.Kind() == reflect.String {
+		key.SetString(strconv.FormatInt(i.Value, 10))
+		return nil
+	}
+	return decodeValue(obj, key, path, depth)
+}
+
+func decodeStruct(hash *Hash, v reflect.Value, path string, depth int) error {
+	for _, f := range structFields(v.Type()) {
+		value, ok := hash.Get(&String{Value: f.name})
+		if !ok {
+			continue
+		}
+		if err := decodeValue(value, allocFieldByIndex(v, f.index), path+"."+f.name, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// allocFieldByIndex is like reflect.Value.FieldByIndex but allocates nil
+// embedded pointers on the way.
+func allocFieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// toGo converts obj to its natural Go representation. path and depth locate
+// obj in the value being decoded, as for decodeValue.
+func toGo(obj Object, path string, depth int) (any, error) {
+	if depth > maxConvertDepth {
+		return nil, &ConversionError{Path: path, Message: "value is nested too deeply (cyclic?)"}
+	}
+
+	switch obj := obj.(type) {
+	case *Integer:
+		return obj.Value, nil
+	case *Float:
+		return obj.Value, nil
+	case *String:
+		return obj.Value, nil
+	case *Boolean:
+		return obj.Value, nil
+	case *Null:
+		return nil, nil
+	case *Array:
+		elements := make([]any, len(obj.Elements))
+		for i, elem := range obj.Elements {
+			value, err := toGo(elem, fmt.Sprintf("%s[%d]", path, i), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = value
+		}
+		return elements, nil
+	case *Hash:
+		m := make(map[string]any, obj.Len())
+		for _, pair := range obj.Pairs() {
+			key := pair.Key.Inspect()
+			value, err := toGo(pair.Value, fmt.Sprintf("%s[%s]", path, key), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = value
+		}
+		return m, nil
+	default:
+		return obj, nil
+	}
+}
+
+func mismatch(path, want string, got Object) error {
+	return &ConversionError{Path: path, Message: fmt.Sprintf("expected %s, got %s", want, got.Type())}
+}
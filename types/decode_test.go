@@ -0,0 +1,118 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type decodeItem struct {
+	Name  string  `moxy:"name"`
+	Price float64 `moxy:"price"`
+}
+
+type decodeOrder struct {
+	Items []decodeItem   `moxy:"items"`
+	Tags  map[string]int `moxy:"tags"`
+	Count uint8          `moxy:"count"`
+	Note  *string        `moxy:"note"`
+}
+
+func TestDecode(t *testing.T) {
+	input := map[string]any{
+		"items": []any{
+			map[string]any{"name": "a", "price": 1},
+			map[string]any{"name": "b", "price": 2.5},
+		},
+		"tags":    map[string]any{"x": 1},
+		"count":   2,
+		"note":    "gift",
+		"ignored": true,
+	}
+
+	obj, err := FromGo(input)
+	if err != nil {
+		t.Fatalf("FromGo: %s", err)
+	}
+
+	var o decodeOrder
+	if err := Decode(obj, &o); err != nil {
+		t.Fatalf("Decode: %s", err)
+	}
+	got := fmt.Sprintf("%v %v %d %s", o.Items, o.Tags, o.Count, *o.Note)
+	if expected := "[{a 1} {b 2.5}] map[x:1] 2 gift"; got != expected {
+		t.Fatalf("expected=%s, got=%s", expected, got)
+	}
+
+	var generic any
+	if err := Decode(obj, &generic); err != nil {
+		t.Fatalf("Decode into any: %s", err)
+	}
+	m := generic.(map[string]any)
+	if fmt.Sprint(m["items"].([]any)[1]) != "map[name:b price:2.5]" || m["count"] != int64(2) {
+		t.Fatalf("Decode into any - got=%v", generic)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		input    any
+		expected string
+	}{
+		{map[string]any{"items": []any{
+			map[string]any{"price": 1}, map[string]any{"price": 2},
+			map[string]any{"price": 3}, map[string]any{"price": "4"},
+		}}, `result.items[3].price: expected number, got STRING`},
+		{map[string]any{"items": map[string]any{"price": 1}}, `result.items: expected array, got HASH`},
+		{map[string]any{"items": []any{map[string]any{"name": 7}}}, `result.items[0].name: expected string, got INTEGER`},
+		{map[string]any{"tags": map[string]any{"y": true}}, `result.tags[y]: expected integer, got BOOLEAN`},
+		{map[string]any{"count": 300}, `result.count: 300 overflows uint8`},
+		{[]int{1}, `result: expected hash, got ARRAY`},
+	}
+
+	for i, tt := range tests {
+		obj, err := FromGo(tt.input)
+		if err != nil {
+			t.Fatalf("tests[%d] - FromGo: %s", i, err)
+		}
+
+		var o decodeOrder
+		err = Decode(obj, &o)
+		var cerr *ConversionError
+		if !errors.As(err, &cerr) || err.Error() != tt.expected {
+			t.Fatalf("tests[%d] - expected=%q, got=%v", i, tt.expected, err)
+		}
+	}
+
+	if err := Decode(NULL, decodeOrder{}); err == nil {
+		t.Fatalf("expected an error for a non-pointer target")
+	}
+}
+
+func TestDecodeCycles(t *testing.T) {
+	array := &Array{Elements: []Object{&Integer{Value: 0}}}
+	array.Elements[0] = array
+
+	key := &String{Value: "self"}
+	hash := NewHash(1)
+	hash.Set(key, hash)
+
+	tests := []struct {
+		input  Object
+		target any
+	}{
+		{array, new(any)},
+		{array, new([][]any)},
+		{hash, new(map[string]any)},
+		{hash, new(any)},
+	}
+
+	for i, tt := range tests {
+		err := Decode(tt.input, tt.target)
+		var cerr *ConversionError
+		if !errors.As(err, &cerr) || !strings.Contains(cerr.Message, "nested too deeply") {
+			t.Fatalf("tests[%d] - expected nesting ConversionError, got=%v", i, err)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package types
+
+import (
+	"fmt"
+	"reflect"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// WrapGoFunc turns an ordinary Go function into a Builtin. Arguments are
+// decoded into the function's parameter types with the rules of Decode and
+// results are converted back with FromGo. The function may return nothing, a
+// value, an error, or a value and an error; a non-nil error becomes an ERROR
+// object carrying it, and so does a panic, which is recovered. Variadic
+// functions accept any number of trailing arguments.
+func WrapGoFunc(name string, fn any) (*Builtin, error) {
+	fv := reflect.ValueOf(fn)
+	if fv.Kind() != reflect.Func || fv.IsNil() {
+		return nil, fmt.Errorf("%s: expected a function, got %T", name, fn)
+	}
+
+	g, err := newGoFunc(name, fv, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &Builtin{Fn: func(args ...Object) Object {
+		return g.call(nil, args)
+	}}, nil
+}
+
+// goFunc is a Go function checked once for calls from scripts. Its first
+// bound parameters, such as a method receiver, are supplied by the caller
+// rather than the script.
+type goFunc struct {
+	name         string
+	fn           reflect.Value
+	params       []reflect.Type // the parameters scripts pass
+	fixed        int            // len(params) without the variadic one
+	variadic     bool
+	returnsError bool
+}
+
+func newGoFunc(name string, fv reflect.Value, bound int) (*goFunc, error) {
+	ft := fv.Type()
+	returnsError := ft.NumOut() > 0 && ft.Out(ft.NumOut()-1) == errorType
+	switch {
+	case ft.NumOut() > 2:
+		return nil, fmt.Errorf("%s: function returns %d values, want at most 2", name, ft.NumOut())
+	case ft.NumOut() == 2 && !returnsError:
+		return nil, fmt.Errorf("%s: second result must be an error, got %s", name, ft.Out(1))
+	}
+
+	params := make([]reflect.Type, ft.NumIn()-bound)
+	for i := range params {
+		params[i] = ft.In(bound + i)
+	}
+	fixed := len(params)
+	if ft.IsVariadic() {
+		fixed--
+	}
+
+	return &goFunc{
+		name:         name,
+		fn:           fv,
+		params:       params,
+		fixed:        fixed,
+		variadic:     ft.IsVariadic(),
+		returnsError: returnsError,
+	}, nil
+}
+
+// call decodes args, calls the function with bound followed by them and
+// converts the result.
+func (g *goFunc) call(bound []reflect.Value, args []Object) (result Object) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = goError(g.name, panicError(r))
+		}
+	}()
+
+	if len(args) < g.fixed || (!g.variadic && len(args) > g.fixed) {
+		if g.variadic {
+			return &Error{Message: fmt.Sprintf("%s: wrong number of arguments: want at least %d, got=%d", g.name, g.fixed, len(args))}
+		}
+		return &Error{Message: fmt.Sprintf("%s: wrong number of arguments: want=%d, got=%d", g.name, g.fixed, len(args))}
+	}
+
+	in := make([]reflect.Value, 0, len(bound)+len(args))
+	in = append(in, bound...)
+	for i, arg := range args {
+		var pt reflect.Type
+		if i < g.fixed {
+			pt = g.params[i]
+		} else {
+			pt = g.params[g.fixed].Elem()
+		}
+
+		v := reflect.New(pt).Elem()
+		if err := decodeValue(arg, v, fmt.Sprintf("argument %d", i+1), 0); err != nil {
+			return goError(g.name, err)
+		}
+		in = append(in, v)
+	}
+
+	out := g.fn.Call(in)
+
+	if g.returnsError {
+		if err, _ := out[len(out)-1].Interface().(error); err != nil {
+			return goError(g.name, err)
+		}
+		out = out[:len(out)-1]
+	}
+	if len(out) == 0 {
+		return NULL
+	}
+
+	value, err := fromGoValue(out[0], "result", 0)
+	if err != nil {
+		return goError(g.name, err)
+	}
+	return value
+}
+
+// panicError turns a value recovered from a host function into an error,
+// keeping it reachable through errors.Is and errors.As when it is one.
+func panicError(r any) error {
+	if err, ok := r.(error); ok {
+		return fmt.Errorf("panic: %w", err)
+	}
+	return fmt.Errorf("panic: %v", r)
+}
+
+// goError wraps an error raised while calling the host function name.
+func goError(name string, err error) *Error {
+	err = fmt.Errorf("%s: %w", name, err)
+	return &Error{Message: err.Error(), Err: err}
+}
@@ -0,0 +1,89 @@
+package types
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWrapGoFunc(t *testing.T) {
+	errDenied := errors.New("denied")
+
+	tests := []struct {
+		fn       any
+		args     []Object
+		expected string
+	}{
+		{func(a, b int) int { return a + b }, []Object{&Integer{Value: 2}, &Integer{Value: 3}}, "5"},
+		{func(s string, n uint8) []string { return []string{s, s}[:n] }, []Object{&String{Value: "x"}, &Integer{Value: 1}}, "[x]"},
+		{func(f float64) float64 { return f / 2 }, []Object{&Integer{Value: 3}}, "1.5"},
+		{func(prefix string, xs ...int) int { return len(prefix) + len(xs) }, []Object{&String{Value: "ab"}, &Integer{Value: 1}, &Integer{Value: 2}}, "4"},
+		{func(prefix string, xs ...int) int { return len(prefix) + len(xs) }, []Object{&String{Value: "ab"}}, "2"},
+		{func() {}, nil, "null"},
+		{func() (int, error) { return 7, nil }, nil, "7"},
+		{func(o Object) Object { return o }, []Object{TRUE}, "true"},
+	}
+
+	for i, tt := range tests {
+		builtin, err := WrapGoFunc("f", tt.fn)
+		if err != nil {
+			t.Fatalf("tests[%d] - WrapGoFunc: %s", i, err)
+		}
+		result := builtin.Fn(tt.args...)
+		if result.Inspect() != tt.expected {
+			t.Fatalf("tests[%d] - expected=%s, got=%s", i, tt.expected, result.Inspect())
+		}
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			fn       any
+			args     []Object
+			expected string
+		}{
+			{func(a int) int { return a }, nil, "f: wrong number of arguments: want=1, got=0"},
+			{func(a int, xs ...int) int { return a }, nil, "f: wrong number of arguments: want at least 1, got=0"},
+			{func(a int) int { return a }, []Object{&String{Value: "1"}}, "f: argument 1: expected integer, got STRING"},
+			{func() (int, error) { return 0, errDenied }, nil, "f: denied"},
+			{func() int { panic("boom") }, nil, "f: panic: boom"},
+			{func(xs []int) int { return xs[3] }, []Object{&Array{Elements: []Object{&Integer{Value: 1}}}}, "f: panic: runtime error: index out of range [3] with length 1"},
+			{func() int { panic(errDenied) }, nil, "f: panic: denied"},
+			{func() chan int { return nil }, nil, "f: result: unsupported type chan int"},
+		}
+
+		for i, tt := range tests {
+			builtin, err := WrapGoFunc("f", tt.fn)
+			if err != nil {
+				t.Fatalf("tests[%d] - WrapGoFunc: %s", i, err)
+			}
+			errObj, ok := builtin.Fn(tt.args...).(*Error)
+			if !ok || errObj.Message != tt.expected {
+				t.Fatalf("tests[%d] - expected error %q, got=%v", i, tt.expected, errObj)
+			}
+		}
+	})
+
+	// Returned and panicked errors stay reachable for errors.Is.
+	for _, fn := range []any{
+		func() error { return errDenied },
+		func() int { panic(errDenied) },
+	} {
+		builtin, _ := WrapGoFunc("f", fn)
+		errObj := builtin.Fn().(*Error)
+		if !errors.Is(errObj.Err, errDenied) {
+			t.Fatalf("expected errors.Is(%v, errDenied)", errObj.Err)
+		}
+	}
+}
+
+func TestWrapGoFuncRejectsSignatures(t *testing.T) {
+	for i, fn := range []any{
+		42,
+		(func())(nil),
+		func() (int, int) { return 0, 0 },
+		func() (int, error, error) { return 0, nil, nil },
+	} {
+		if _, err := WrapGoFunc("f", fn); err == nil {
+			t.Fatalf("tests[%d] - expected WrapGoFunc to reject %T", i, fn)
+		}
+	}
+}
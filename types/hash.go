@@ -2,8 +2,11 @@ package types
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"hash/fnv"
+	"iter"
+	"slices"
 	"strings"
 )
 
@@ -18,28 +21,141 @@ type Hashable interface {
 	HashKey() HashKey
 }
 
+// HashKeyOf returns the hash key of obj, and false if obj cannot be used as
+// a hash key. Besides Hashable objects, an array whose elements are all
+// Hashable can be a key.
+func HashKeyOf(obj Object) (HashKey, bool) {
+	switch obj := obj.(type) {
+	case Hashable:
+		return obj.HashKey(), true
+	case *Array:
+		h := fnv.New64a()
+		var buf [8]byte
+		for _, elem := range obj.Elements {
+			hashable, ok := elem.(Hashable)
+			if !ok {
+				return HashKey{}, false
+			}
+			key := hashable.HashKey()
+			h.Write([]byte(key.Type))
+			binary.BigEndian.PutUint64(buf[:], key.Value)
+			h.Write(buf[:])
+		}
+		return HashKey{Type: ARRAY_OBJ, Value: h.Sum64()}, true
+	default:
+		return HashKey{}, false
+	}
+}
+
 // HashPair represents a key-value pair in a hash
 type HashPair struct {
 	Key   Object
 	Value Object
 }
 
-// Hash represents a hash map object
+// Hash is an ordered map from hashable objects to objects. Pairs keep the
+// order in which their keys were first added, lookups take constant time,
+// and keys whose HashKeys collide are told apart by comparing the keys
+// themselves. The zero value is an empty hash.
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	pairs   []HashPair        // in insertion order
+	buckets map[HashKey][]int // indexes into pairs, by the key's HashKey
+}
+
+// NewHash returns an empty hash with room for size pairs.
+func NewHash(size int) *Hash {
+	return &Hash{
+		pairs:   make([]HashPair, 0, size),
+		buckets: make(map[HashKey][]int, size),
+	}
+}
+
+// Len returns the number of pairs in h.
+func (h *Hash) Len() int { return len(h.pairs) }
+
+// Get returns the value for key, and false if key is not in h or is not
+// hashable.
+func (h *Hash) Get(key Object) (Object, bool) {
+	hashKey, ok := HashKeyOf(key)
+	if !ok {
+		return nil, false
+	}
+	i := h.find(key, hashKey)
+	if i < 0 {
+		return nil, false
+	}
+	return h.pairs[i].Value, true
+}
+
+// Set adds or replaces the value for key. A new key goes after the existing
+// ones; replacing a value keeps the key's place. An array key is copied, so
+// changing the array later does not change the key.
+func (h *Hash) Set(key, value Object) error {
+	hashKey, ok := HashKeyOf(key)
+	if !ok {
+		return fmt.Errorf("unusable as hash key: %s", key.Type())
+	}
+
+	if i := h.find(key, hashKey); i >= 0 {
+		h.pairs[i].Value = value
+		return nil
+	}
+
+	if h.buckets == nil {
+		h.buckets = make(map[HashKey][]int)
+	}
+	if arr, ok := key.(*Array); ok {
+		key = &Array{Elements: slices.Clone(arr.Elements)}
+	}
+	h.buckets[hashKey] = append(h.buckets[hashKey], len(h.pairs))
+	h.pairs = append(h.pairs, HashPair{Key: key, Value: value})
+	return nil
+}
+
+// Pairs returns the pairs of h in insertion order. The slice belongs to h
+// and must not be modified.
+func (h *Hash) Pairs() []HashPair { return h.pairs }
+
+// All returns an iterator over the keys and values of h in insertion order.
+func (h *Hash) All() iter.Seq2[Object, Object] {
+	return func(yield func(Object, Object) bool) {
+		for _, pair := range h.pairs {
+			if !yield(pair.Key, pair.Value) {
+				return
+			}
+		}
+	}
+}
+
+// find returns the index in h.pairs of key, whose HashKey is hashKey, or -1.
+func (h *Hash) find(key Object, hashKey HashKey) int {
+	for _, i := range h.buckets[hashKey] {
+		if Equal(h.pairs[i].Key, key) {
+			return i
+		}
+	}
+	return -1
 }
 
 // Type returns the type of the object
 func (h *Hash) Type() ObjectType { return HASH_OBJ }
 
 // Inspect returns a string representation of the hash
-func (h *Hash) Inspect() string {
+func (h *Hash) Inspect() string { return h.inspect(nil) }
+
+func (h *Hash) inspect(seen inspecting) string {
+	if seen[h] {
+		return "{...}"
+	}
+	seen = seen.enter(h)
+	defer delete(seen, h)
+
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for _, pair := range h.Pairs {
+	for _, pair := range h.pairs {
 		pairs = append(pairs, fmt.Sprintf("%s: %s",
-			pair.Key.Inspect(), pair.Value.Inspect()))
+			inspect(pair.Key, seen), inspect(pair.Value, seen)))
 	}
 
 	out.WriteString("{")
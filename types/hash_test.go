@@ -0,0 +1,76 @@
+package types
+
+import (
+	"strings"
+	"testing"
+)
+
+// collidingKey is a hashable value whose keys all share one HashKey, so a
+// hash can only tell them apart by comparing the keys themselves.
+type collidingKey struct{ name string }
+
+func (k collidingKey) Type() ObjectType { return "KEY" }
+func (k collidingKey) Inspect() string  { return k.name }
+func (k collidingKey) HashKey() HashKey { return HashKey{Type: "KEY", Value: 42} }
+
+func TestHashCollisions(t *testing.T) {
+	a, b, c := collidingKey{"a"}, collidingKey{"b"}, collidingKey{"c"}
+
+	h := NewHash(0)
+	for i, key := range []Object{a, b, c} {
+		if err := h.Set(key, &Integer{Value: int64(i + 1)}); err != nil {
+			t.Fatalf("Set(%s): %s", key.Inspect(), err)
+		}
+	}
+	if err := h.Set(b, &Integer{Value: 20}); err != nil {
+		t.Fatalf("Set(b): %s", err)
+	}
+
+	if h.Len() != 3 {
+		t.Fatalf("Len - expected=3, got=%d", h.Len())
+	}
+	if got := h.Inspect(); got != "{a: 1, b: 20, c: 3}" {
+		t.Fatalf("Inspect - expected=%q, got=%q", "{a: 1, b: 20, c: 3}", got)
+	}
+	for key, expected := range map[Object]string{a: "1", b: "20", c: "3"} {
+		if value, ok := h.Get(key); !ok || value.Inspect() != expected {
+			t.Fatalf("Get(%s) - expected=%s, got=%v", key.Inspect(), expected, value)
+		}
+	}
+	if _, ok := h.Get(collidingKey{"d"}); ok {
+		t.Fatalf("Get(d) - expected no value for a colliding key that was never set")
+	}
+}
+
+func TestHashOrder(t *testing.T) {
+	h := NewHash(0)
+	for _, name := range []string{"c", "a", "b", "a"} {
+		if err := h.Set(&String{Value: name}, &String{Value: strings.ToUpper(name)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Keys of other types join the same order.
+	h.Set(&Integer{Value: 1}, TRUE)
+	h.Set(TRUE, &Integer{Value: 1})
+
+	var got []string
+	for key, value := range h.All() {
+		got = append(got, key.Inspect()+"="+value.Inspect())
+	}
+	expected := "c=C a=A b=B 1=true true=1"
+	if strings.Join(got, " ") != expected {
+		t.Fatalf("All - expected=%q, got=%q", expected, strings.Join(got, " "))
+	}
+
+	if err := h.Set(NewHash(0), NULL); err == nil {
+		t.Fatalf("Set - expected a hash key to be rejected")
+	}
+	if _, ok := h.Get(NewHash(0)); ok {
+		t.Fatalf("Get - expected no value for an unhashable key")
+	}
+
+	var empty Hash
+	if empty.Len() != 0 || empty.Set(&String{Value: "k"}, NULL) != nil || empty.Len() != 1 {
+		t.Fatalf("expected the zero Hash to be usable")
+	}
+}
@@ -0,0 +1,273 @@
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// HostMethod implements a script-visible method of a host object. receiver is
+// the wrapped Go value, so one method table can serve many objects.
+type HostMethod func(receiver any, args ...Object) Object
+
+// HostObject exposes a live Go value to scripts without copying it. Scripts
+// read exported struct fields and call methods by name, e.g. cart["Total"] or
+// cart["Add"](item); writes go through SetProperty and change the Go value.
+// Names follow the `moxy` struct tags used by FromGo.
+//
+// Wrap a pointer to anything scripts should change. A struct held by value is
+// a copy: its fields cannot be set and methods with pointer receivers are not
+// visible. Field reads are converted with FromGo, so a nested struct, slice or
+// map reaches the script as a copy too, and index assignments on it do not
+// write back; expose such values as their own HostObject instead.
+//
+// The wrapped value can take over indexing, iteration, equality and
+// arithmetic by implementing HostIndexer, HostIterable, HostEqualer and
+// HostOperator.
+type HostObject struct {
+	Value   any
+	Methods map[string]HostMethod // consulted before reflected methods and fields
+}
+
+// NewHostObject wraps v. Fields can only be set when v is a pointer to a
+// struct; SetProperty reports an error otherwise.
+func NewHostObject(v any) *HostObject {
+	return &HostObject{Value: v}
+}
+
+// WithMethods sets the method table of h and returns h.
+func (h *HostObject) WithMethods(methods map[string]HostMethod) *HostObject {
+	h.Methods = methods
+	return h
+}
+
+func (h *HostObject) Type() ObjectType { return HOST_OBJ }
+
+func (h *HostObject) Inspect() string {
+	if s, ok := h.Value.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("<host %T>", h.Value)
+}
+
+// HostIndexer is implemented by host values that handle obj[key] themselves.
+type HostIndexer interface {
+	Index(key Object) (Object, error)
+}
+
+// HostIndexSetter is implemented by host values that handle obj[key] = value
+// themselves.
+type HostIndexSetter interface {
+	SetIndex(key, value Object) error
+}
+
+// HostIterator yields the elements of a host collection one at a time.
+type HostIterator interface {
+	Next() (key, value Object, ok bool)
+}
+
+// HostIterable is implemented by host values that scripts can iterate over.
+type HostIterable interface {
+	Iterate() HostIterator
+}
+
+// HostEqualer is implemented by host values that define == against other
+// objects. Without it two host objects are equal when they wrap the same
+// comparable Go value.
+type HostEqualer interface {
+	Equal(other Object) bool
+}
+
+// HostOperator is implemented by host values that support binary operators
+// such as + or <. reversed is true when the host value is the right operand.
+type HostOperator interface {
+	BinaryOp(op string, other Object, reversed bool) (Object, error)
+}
+
+// Index evaluates h[key]. Unless the wrapped value is a HostIndexer, key
+// must be a string naming a property.
+func (h *HostObject) Index(key Object) (Object, error) {
+	if indexer, ok := h.Value.(HostIndexer); ok {
+		return orNull(indexer.Index(key))
+	}
+
+	name, ok := key.(*String)
+	if !ok {
+		return nil, fmt.Errorf("unusable as %s property name: %s", h.typeName(), key.Type())
+	}
+	return h.GetProperty(name.Value)
+}
+
+// SetIndex performs h[key] = value. Unless the wrapped value is a
+// HostIndexSetter, key must be a string naming a settable field.
+func (h *HostObject) SetIndex(key, value Object) error {
+	if setter, ok := h.Value.(HostIndexSetter); ok {
+		return setter.SetIndex(key, value)
+	}
+
+	name, ok := key.(*String)
+	if !ok {
+		return fmt.Errorf("unusable as %s property name: %s", h.typeName(), key.Type())
+	}
+	return h.SetProperty(name.Value, value)
+}
+
+// GetProperty returns the method or field called name. Methods are returned
+// as builtins bound to the wrapped value.
+func (h *HostObject) GetProperty(name string) (Object, error) {
+	if method, ok := h.Methods[name]; ok {
+		return &Builtin{Fn: func(args ...Object) Object {
+			return method(h.Value, args...)
+		}}, nil
+	}
+
+	v := reflect.ValueOf(h.Value)
+	if !v.IsValid() {
+		return nil, fmt.Errorf("undefined property %s on %s", name, h.typeName())
+	}
+
+	if method, err := hostMethod(v.Type(), name); method != nil || err != nil {
+		if err != nil {
+			return nil, err
+		}
+		return &Builtin{Fn: func(args ...Object) Object {
+			return method.call([]reflect.Value{v}, args)
+		}}, nil
+	}
+
+	if field, ok := h.field(name); ok {
+		return fromGoValue(field, name, 0)
+	}
+
+	return nil, fmt.Errorf("undefined property %s on %s", name, h.typeName())
+}
+
+type hostMethodKey struct {
+	t    reflect.Type
+	name string
+}
+
+type hostMethodEntry struct {
+	method *goFunc // nil when t has no such method
+	err    error
+}
+
+var hostMethodCache sync.Map // hostMethodKey -> hostMethodEntry
+
+// hostMethod returns the exported method name of t, checked for calls from
+// scripts. The result is cached per type, so looking up a method on every
+// access does not repeat the reflection.
+func hostMethod(t reflect.Type, name string) (*goFunc, error) {
+	key := hostMethodKey{t, name}
+	if cached, ok := hostMethodCache.Load(key); ok {
+		entry := cached.(hostMethodEntry)
+		return entry.method, entry.err
+	}
+
+	var entry hostMethodEntry
+	if m, ok := t.MethodByName(name); ok && m.IsExported() {
+		entry.method, entry.err = newGoFunc(fmt.Sprintf("%s.%s", t, name), m.Func, 1)
+	}
+	hostMethodCache.Store(key, entry)
+	return entry.method, entry.err
+}
+
+// SetProperty decodes value into the field called name.
+func (h *HostObject) SetProperty(name string, value Object) error {
+	field, ok := h.field(name)
+	if !ok {
+		return fmt.Errorf("undefined property %s on %s", name, h.typeName())
+	}
+	if !field.CanSet() {
+		return fmt.Errorf("cannot set property %s on %s: not a pointer to a struct", name, h.typeName())
+	}
+	return decodeValue(value, field, name, 0)
+}
+
+// field finds the struct field called name, looking through pointers.
+func (h *HostObject) field(name string) (reflect.Value, bool) {
+	v := reflect.ValueOf(h.Value)
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}, false
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+
+	for _, f := range structFields(v.Type()) {
+		if f.name == name {
+			return fieldByIndex(v, f.index)
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func (h *HostObject) typeName() string {
+	return fmt.Sprintf("%T", h.Value)
+}
+
+// HostBinaryOp evaluates left op right where at least one operand is a
+// *HostObject. == and != use HostEqualer; other operators need a
+// HostOperator on either side, the left operand taking precedence.
+func HostBinaryOp(op string, left, right Object) (Object, error) {
+	switch op {
+	case "==":
+		return nativeBool(hostEqual(left, right)), nil
+	case "!=":
+		return nativeBool(!hostEqual(left, right)), nil
+	}
+
+	if h, ok := left.(*HostObject); ok {
+		if operator, ok := h.Value.(HostOperator); ok {
+			return orNull(operator.BinaryOp(op, right, false))
+		}
+	}
+	if h, ok := right.(*HostObject); ok {
+		if operator, ok := h.Value.(HostOperator); ok {
+			return orNull(operator.BinaryOp(op, left, true))
+		}
+	}
+	return nil, fmt.Errorf("unsupported types for binary operation: %s %s %s", left.Type(), op, right.Type())
+}
+
+func hostEqual(left, right Object) bool {
+	if h, ok := left.(*HostObject); ok {
+		if eq, ok := h.Value.(HostEqualer); ok {
+			return eq.Equal(right)
+		}
+	}
+	if h, ok := right.(*HostObject); ok {
+		if eq, ok := h.Value.(HostEqualer); ok {
+			return eq.Equal(left)
+		}
+	}
+
+	l, ok := left.(*HostObject)
+	if !ok {
+		return false
+	}
+	r, ok := right.(*HostObject)
+	if !ok {
+		return false
+	}
+	if l == r {
+		return true
+	}
+
+	lv, rv := reflect.ValueOf(l.Value), reflect.ValueOf(r.Value)
+	if !lv.IsValid() || !rv.IsValid() {
+		return lv.IsValid() == rv.IsValid()
+	}
+	return lv.Type() == rv.Type() && lv.Comparable() && lv.Equal(rv)
+}
+
+// orNull turns the nil result of a successful hook into NULL.
+func orNull(obj Object, err error) (Object, error) {
+	if err == nil && obj == nil {
+		return NULL, nil
+	}
+	return obj, err
+}
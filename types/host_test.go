@@ -0,0 +1,203 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type hostCart struct {
+	Owner string `moxy:"owner"`
+	Items []string
+	total int
+}
+
+func (c *hostCart) Add(item string, price int) int {
+	c.Items = append(c.Items, item)
+	c.total += price
+	return c.total
+}
+
+func (c hostCart) Count() int { return len(c.Items) }
+
+func (c *hostCart) Checkout() error {
+	if len(c.Items) == 0 {
+		return errors.New("empty cart")
+	}
+	return nil
+}
+
+func TestHostObjectProperties(t *testing.T) {
+	cart := &hostCart{Owner: "ada"}
+	h := NewHostObject(cart)
+
+	owner, err := h.GetProperty("owner")
+	if err != nil || owner.Inspect() != "ada" {
+		t.Fatalf("owner - expected ada, got=%v (%v)", owner, err)
+	}
+
+	if err := h.SetProperty("owner", &String{Value: "bob"}); err != nil {
+		t.Fatalf("SetProperty: %s", err)
+	}
+	if cart.Owner != "bob" {
+		t.Fatalf("SetProperty did not write through, Owner=%q", cart.Owner)
+	}
+	if err := h.SetIndex(&String{Value: "owner"}, &Integer{Value: 1}); err == nil || !strings.Contains(err.Error(), "expected string") {
+		t.Fatalf("SetIndex with an INTEGER - expected a decode error, got=%v", err)
+	}
+
+	add, err := h.Index(&String{Value: "Add"})
+	if err != nil {
+		t.Fatalf("Index(Add): %s", err)
+	}
+	add.(*Builtin).Fn(&String{Value: "pen"}, &Integer{Value: 3})
+	total := add.(*Builtin).Fn(&String{Value: "ink"}, &Integer{Value: 4})
+	if total.Inspect() != "7" || cart.total != 7 {
+		t.Fatalf("Add - expected total 7, got=%s (cart %d)", total.Inspect(), cart.total)
+	}
+
+	// A value-receiver method is reachable through the pointer too.
+	count, _ := h.GetProperty("Count")
+	if got := count.(*Builtin).Fn(); got.Inspect() != "2" {
+		t.Fatalf("Count - expected 2, got=%s", got.Inspect())
+	}
+
+	checkout, _ := NewHostObject(&hostCart{}).GetProperty("Checkout")
+	if errObj, ok := checkout.(*Builtin).Fn().(*Error); !ok || !strings.HasSuffix(errObj.Message, "empty cart") {
+		t.Fatalf("Checkout - expected the method's error, got=%v", errObj)
+	}
+
+	for _, name := range []string{"missing", "total", "add"} {
+		if _, err := h.GetProperty(name); err == nil {
+			t.Fatalf("GetProperty(%s) - expected an error", name)
+		}
+	}
+	if _, err := h.Index(&Integer{Value: 0}); err == nil {
+		t.Fatalf("Index(0) - expected an error")
+	}
+}
+
+// A struct held by value is a copy: reads work, writes are rejected rather
+// than lost, and pointer-receiver methods are not visible.
+func TestHostObjectByValue(t *testing.T) {
+	h := NewHostObject(hostCart{Owner: "ada", Items: []string{"pen"}})
+
+	if owner, err := h.GetProperty("owner"); err != nil || owner.Inspect() != "ada" {
+		t.Fatalf("owner - expected ada, got=%v (%v)", owner, err)
+	}
+	if err := h.SetProperty("owner", &String{Value: "bob"}); err == nil {
+		t.Fatalf("SetProperty on a struct value - expected an error")
+	}
+	if count, err := h.GetProperty("Count"); err != nil || count.(*Builtin).Fn().Inspect() != "1" {
+		t.Fatalf("Count - expected 1, got=%v (%v)", count, err)
+	}
+	if _, err := h.GetProperty("Add"); err == nil {
+		t.Fatalf("Add on a struct value - expected an error")
+	}
+}
+
+func TestHostObjectMethods(t *testing.T) {
+	cart := &hostCart{Owner: "ada"}
+	h := NewHostObject(cart).WithMethods(map[string]HostMethod{
+		"owner": func(receiver any, args ...Object) Object {
+			return &String{Value: "method " + receiver.(*hostCart).Owner}
+		},
+	})
+
+	// The method table wins over the field of the same name.
+	owner, _ := h.GetProperty("owner")
+	if got := owner.(*Builtin).Fn(); got.Inspect() != "method ada" {
+		t.Fatalf("expected the table method, got=%s", got.Inspect())
+	}
+}
+
+// hostVector implements every hook.
+type hostVector struct{ X, Y int64 }
+
+func (v hostVector) Index(key Object) (Object, error) {
+	switch key.Inspect() {
+	case "0":
+		return &Integer{Value: v.X}, nil
+	case "1":
+		return &Integer{Value: v.Y}, nil
+	}
+	return nil, nil
+}
+
+func (v *hostVector) SetIndex(key, value Object) error {
+	if key.Inspect() != "0" {
+		return fmt.Errorf("read-only index %s", key.Inspect())
+	}
+	v.X = value.(*Integer).Value
+	return nil
+}
+
+func (v hostVector) Equal(other Object) bool {
+	o, ok := other.(*HostObject)
+	if !ok {
+		return false
+	}
+	ov, ok := o.Value.(hostVector)
+	return ok && ov == v
+}
+
+func (v hostVector) BinaryOp(op string, other Object, reversed bool) (Object, error) {
+	n, ok := other.(*Integer)
+	if !ok || op != "*" {
+		return nil, fmt.Errorf("unsupported %s", op)
+	}
+	return NewHostObject(hostVector{v.X * n.Value, v.Y * n.Value}), nil
+}
+
+func (v hostVector) String() string { return fmt.Sprintf("(%d, %d)", v.X, v.Y) }
+
+func TestHostObjectHooks(t *testing.T) {
+	v := NewHostObject(hostVector{1, 2})
+
+	if got, _ := v.Index(&Integer{Value: 1}); got.Inspect() != "2" {
+		t.Fatalf("Index(1) - expected 2, got=%s", got.Inspect())
+	}
+	if got, err := v.Index(&Integer{Value: 5}); err != nil || got != NULL {
+		t.Fatalf("Index(5) - expected NULL, got=%v (%v)", got, err)
+	}
+
+	p := &hostVector{1, 2}
+	if err := NewHostObject(p).SetIndex(&Integer{Value: 0}, &Integer{Value: 9}); err != nil || p.X != 9 {
+		t.Fatalf("SetIndex - expected X=9, got=%d (%v)", p.X, err)
+	}
+	if err := NewHostObject(p).SetIndex(&Integer{Value: 1}, &Integer{Value: 9}); err == nil {
+		t.Fatalf("SetIndex(1) - expected the hook's error")
+	}
+
+	tests := []struct {
+		op          string
+		left, right Object
+		expected    string
+	}{
+		{"==", v, NewHostObject(hostVector{1, 2}), "true"},
+		{"!=", v, NewHostObject(hostVector{1, 3}), "true"},
+		{"==", v, &Integer{Value: 1}, "false"},
+		{"*", v, &Integer{Value: 3}, "(3, 6)"},
+		{"*", &Integer{Value: 2}, v, "(2, 4)"},
+		{"==", NewHostObject(7), NewHostObject(7), "true"},
+		{"==", NewHostObject([]int{1}), NewHostObject([]int{1}), "false"},
+	}
+
+	for i, tt := range tests {
+		result, err := HostBinaryOp(tt.op, tt.left, tt.right)
+		if err != nil {
+			t.Fatalf("tests[%d] - unexpected error: %s", i, err)
+		}
+		if result.Inspect() != tt.expected {
+			t.Fatalf("tests[%d] - expected=%s, got=%s", i, tt.expected, result.Inspect())
+		}
+	}
+
+	if _, err := HostBinaryOp("-", v, &Integer{Value: 1}); err == nil {
+		t.Fatalf("expected the hook's error for -")
+	}
+	if _, err := HostBinaryOp("+", NewHostObject(7), &Integer{Value: 1}); err == nil {
+		t.Fatalf("expected an error without a HostOperator")
+	}
+}
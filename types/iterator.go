@@ -0,0 +1,101 @@
+package types
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// ITERATOR_OBJ is the type of an Iterator. Iterators only live on the VM
+// stack while a range loop runs; scripts never see them.
+const ITERATOR_OBJ = "ITERATOR"
+
+// Iterator steps through the elements of a value for a range loop. It
+// implements HostIterator.
+type Iterator struct {
+	next   func() (key, value Object, ok bool)
+	source Object // the value being iterated, kept reachable for the memory limit
+}
+
+func (it *Iterator) Type() ObjectType { return ITERATOR_OBJ }
+func (it *Iterator) Inspect() string  { return "<iterator>" }
+
+// Next returns the next key and value, or ok false when there are no more.
+func (it *Iterator) Next() (key, value Object, ok bool) {
+	return it.next()
+}
+
+// NewIterator returns an iterator over obj:
+//
+//   - an array yields each index and element;
+//   - a hash yields each key and value in insertion order;
+//   - a string yields the byte offset of each character and the character
+//     as a one-character string;
+//   - a host object whose value implements HostIterable yields what its
+//     HostIterator does.
+//
+// Arrays and hashes are iterated as they were when NewIterator was called,
+// except that an element or value replaced during the loop is seen with its
+// new value.
+func NewIterator(obj Object) (*Iterator, error) {
+	switch obj := obj.(type) {
+	case *Array:
+		elements := obj.Elements
+		i := 0
+		return &Iterator{next: func() (Object, Object, bool) {
+			if i >= len(elements) {
+				return nil, nil, false
+			}
+			i++
+			return &Integer{Value: int64(i - 1)}, elements[i-1], true
+		}, source: obj}, nil
+
+	case *Hash:
+		n := obj.Len()
+		i := 0
+		return &Iterator{next: func() (Object, Object, bool) {
+			if i >= n {
+				return nil, nil, false
+			}
+			i++
+			pair := obj.pairs[i-1]
+			return pair.Key, pair.Value, true
+		}, source: obj}, nil
+
+	case *String:
+		s := obj.Value
+		offset := 0
+		return &Iterator{next: func() (Object, Object, bool) {
+			if offset >= len(s) {
+				return nil, nil, false
+			}
+			_, size := utf8.DecodeRuneInString(s[offset:])
+			key := &Integer{Value: int64(offset)}
+			value := &String{Value: s[offset : offset+size]}
+			offset += size
+			return key, value, true
+		}, source: obj}, nil
+
+	case *HostObject:
+		iterable, ok := obj.Value.(HostIterable)
+		if !ok {
+			return nil, fmt.Errorf("cannot range over %s", obj.typeName())
+		}
+		hostIter := iterable.Iterate()
+		return &Iterator{next: func() (Object, Object, bool) {
+			key, value, ok := hostIter.Next()
+			if !ok {
+				return nil, nil, false
+			}
+			if key == nil {
+				key = NULL
+			}
+			if value == nil {
+				value = NULL
+			}
+			return key, value, true
+		}}, nil
+
+	default:
+		return nil, fmt.Errorf("cannot range over %s", obj.Type())
+	}
+}
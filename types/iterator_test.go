@@ -0,0 +1,68 @@
+package types
+
+import (
+	"strings"
+	"testing"
+)
+
+type countdown int
+
+func (c countdown) Iterate() HostIterator { return &c }
+
+func (c *countdown) Next() (key, value Object, ok bool) {
+	if *c <= 0 {
+		return nil, nil, false
+	}
+	*c--
+	return nil, &Integer{Value: int64(*c)}, true
+}
+
+func TestNewIterator(t *testing.T) {
+	tests := []struct {
+		obj      Object
+		expected string
+	}{
+		{&Array{Elements: []Object{&String{Value: "a"}, TRUE}}, "0=a 1=true"},
+		{&Array{}, ""},
+		{&String{Value: "hé!"}, "0=h 1=é 3=!"},
+		{NewHostObject(countdown(3)), "null=2 null=1 null=0"},
+	}
+
+	for _, tt := range tests {
+		iter, err := NewIterator(tt.obj)
+		if err != nil {
+			t.Fatalf("%s - %s", tt.obj.Inspect(), err)
+		}
+		var got []string
+		for {
+			key, value, ok := iter.Next()
+			if !ok {
+				break
+			}
+			got = append(got, key.Inspect()+"="+value.Inspect())
+		}
+		if strings.Join(got, " ") != tt.expected {
+			t.Fatalf("%s - expected=%q, got=%q", tt.obj.Inspect(), tt.expected, strings.Join(got, " "))
+		}
+	}
+
+	for _, obj := range []Object{&Integer{Value: 1}, NULL, NewHostObject(struct{}{})} {
+		if _, err := NewIterator(obj); err == nil || !strings.HasPrefix(err.Error(), "cannot range over") {
+			t.Fatalf("%s - expected an error, got=%v", obj.Inspect(), err)
+		}
+	}
+}
+
+func TestIteratorKeepsSourceReachable(t *testing.T) {
+	big := &String{Value: strings.Repeat("x", 1<<16)}
+	iter, err := NewIterator(&Array{Elements: []Object{big}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var r Reachable
+	r.Add(iter)
+	if r.Size() < int64(len(big.Value)) {
+		t.Fatalf("expected the iterated array to be counted, got=%d bytes", r.Size())
+	}
+}
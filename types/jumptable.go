@@ -0,0 +1,47 @@
+package types
+
+import (
+	"fmt"
+	"math"
+)
+
+const JUMP_TABLE_OBJ = "JUMP_TABLE"
+
+// JumpTable is a constant of compiled code that maps the values of a switch
+// whose cases are dense integers to the positions of their clauses, so the
+// VM can select a clause without comparing each case.
+type JumpTable struct {
+	Min     int64 // the value of Targets[0]
+	Targets []int
+	Default int // for values outside Targets and values that are not numbers
+}
+
+// Type returns the type of the object
+func (jt *JumpTable) Type() ObjectType { return JUMP_TABLE_OBJ }
+
+// Inspect returns a string representation of the jump table
+func (jt *JumpTable) Inspect() string {
+	return fmt.Sprintf("JumpTable[%d..%d]", jt.Min, jt.Min+int64(len(jt.Targets))-1)
+}
+
+// Target returns the position to jump to for the switch value obj. Like the
+// case comparison, a float equal to an integer case selects it.
+func (jt *JumpTable) Target(obj Object) int {
+	var v int64
+	switch obj := obj.(type) {
+	case *Integer:
+		v = obj.Value
+	case *Float:
+		if obj.Value != math.Trunc(obj.Value) || obj.Value < math.MinInt64 || obj.Value >= math.MaxInt64 {
+			return jt.Default
+		}
+		v = int64(obj.Value)
+	default:
+		return jt.Default
+	}
+
+	if v < jt.Min || uint64(v-jt.Min) >= uint64(len(jt.Targets)) {
+		return jt.Default
+	}
+	return jt.Targets[v-jt.Min]
+}
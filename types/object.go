@@ -23,8 +23,24 @@ const (
 	BUILTIN_OBJ           = "BUILTIN"
 	ARRAY_OBJ             = "ARRAY"
 	HASH_OBJ              = "HASH"
+	HOST_OBJ              = "HOST"
+	CLOSURE_OBJ           = "CLOSURE"
 )
 
+// TypeNames maps the type names that the cases of a type switch can use to
+// the object types each one matches.
+var TypeNames = map[string][]ObjectType{
+	"int":    {INTEGER_OBJ},
+	"float":  {FLOAT_OBJ},
+	"string": {STRING_OBJ},
+	"bool":   {BOOLEAN_OBJ},
+	"array":  {ARRAY_OBJ},
+	"map":    {HASH_OBJ},
+	"func":   {FUNCTION_OBJ, CLOSURE_OBJ, BUILTIN_OBJ},
+	"host":   {HOST_OBJ},
+	"nil":    {NULL_OBJ},
+}
+
 var (
 	NULL  = &Null{}
 	TRUE  = &Boolean{Value: true}
@@ -87,12 +103,16 @@ func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
 type Error struct {
 	Message string
+	Err     error        // underlying Go error, if any
+	Pos     Position     // where in the script the error was raised
+	Stack   []StackFrame // script calls active at Pos, innermost first
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
 func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
 
 type Function struct {
+	Name       string // declared name, or "" for a function literal
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
@@ -158,16 +178,49 @@ func (e *Environment) Update(name string, val Object) (Object, bool) {
 	return nil, false
 }
 
+// inspecting holds the arrays and hashes whose Inspect is in progress, so a
+// collection that contains itself prints as [...] or {...} instead of
+// recursing forever.
+type inspecting map[Object]bool
+
+// enter marks obj as being inspected, allocating the set if needed.
+func (s inspecting) enter(obj Object) inspecting {
+	if s == nil {
+		s = make(inspecting)
+	}
+	s[obj] = true
+	return s
+}
+
+func inspect(obj Object, seen inspecting) string {
+	switch obj := obj.(type) {
+	case *Array:
+		return obj.inspect(seen)
+	case *Hash:
+		return obj.inspect(seen)
+	default:
+		return obj.Inspect()
+	}
+}
+
 type Array struct {
 	Elements []Object
 }
 
 func (ao *Array) Type() ObjectType { return ARRAY_OBJ }
-func (ao *Array) Inspect() string {
+func (ao *Array) Inspect() string  { return ao.inspect(nil) }
+
+func (ao *Array) inspect(seen inspecting) string {
+	if seen[ao] {
+		return "[...]"
+	}
+	seen = seen.enter(ao)
+	defer delete(seen, ao)
+
 	var out bytes.Buffer
 	elements := []string{}
 	for _, e := range ao.Elements {
-		elements = append(elements, e.Inspect())
+		elements = append(elements, inspect(e, seen))
 	}
 	out.WriteString("[")
 	out.WriteString(strings.Join(elements, ", "))
@@ -0,0 +1,41 @@
+package types
+
+import "testing"
+
+func TestInspectCycles(t *testing.T) {
+	self := &Array{Elements: []Object{&Integer{Value: 0}}}
+	self.Elements[0] = self
+
+	pair := &Array{Elements: []Object{&Integer{Value: 1}, nil}}
+	pair.Elements[1] = &Array{Elements: []Object{pair, pair}}
+
+	key := &String{Value: "self"}
+	hash := NewHash(1)
+	hash.Set(key, hash)
+
+	inner := NewHash(1)
+	outer := &Array{Elements: []Object{inner}}
+	key = &String{Value: "a"}
+	inner.Set(key, outer)
+
+	shared := &Array{Elements: []Object{&Integer{Value: 1}}}
+
+	tests := []struct {
+		input    Object
+		expected string
+	}{
+		{self, "[[...]]"},
+		{pair, "[1, [[...], [...]]]"},
+		{hash, "{self: {...}}"},
+		{outer, "[{a: [...]}]"},
+		// A value that appears twice without containing itself is printed
+		// in full both times.
+		{&Array{Elements: []Object{shared, shared}}, "[[1], [1]]"},
+	}
+
+	for i, tt := range tests {
+		if got := tt.input.Inspect(); got != tt.expected {
+			t.Fatalf("tests[%d] - expected=%q, got=%q", i, tt.expected, got)
+		}
+	}
+}
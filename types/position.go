@@ -0,0 +1,77 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Position is a place in a script. Line and Column are 1-based; Column
+// counts characters, so it matches what an editor shows. The zero Position
+// is unknown.
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+// IsValid reports whether the position is known.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String returns "file:line:column", leaving out the file name when there
+// is none.
+func (p Position) String() string {
+	if !p.IsValid() {
+		if p.File != "" {
+			return p.File
+		}
+		return "-"
+	}
+	if p.File == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// PositionTable maps instruction offsets to the source positions they were
+// compiled from. Entries are sorted by offset; each covers the instructions
+// from its offset up to the next entry's.
+type PositionTable []PositionEntry
+
+type PositionEntry struct {
+	Offset int
+	Pos    Position
+}
+
+// Add records that the instruction at offset came from pos. Offsets must be
+// added in increasing order; an entry at an offset already recorded replaces
+// it.
+func (t PositionTable) Add(offset int, pos Position) PositionTable {
+	if n := len(t); n > 0 {
+		if t[n-1].Offset == offset {
+			t = t[:n-1]
+		} else if t[n-1].Pos == pos {
+			return t
+		}
+	}
+	return append(t, PositionEntry{Offset: offset, Pos: pos})
+}
+
+// Truncate drops the entries for instructions at or after offset.
+func (t PositionTable) Truncate(offset int) PositionTable {
+	for len(t) > 0 && t[len(t)-1].Offset >= offset {
+		t = t[:len(t)-1]
+	}
+	return t
+}
+
+// Lookup returns the source position of the instruction at offset, or the
+// zero Position if none was recorded.
+func (t PositionTable) Lookup(offset int) Position {
+	i := sort.Search(len(t), func(i int) bool { return t[i].Offset > offset })
+	if i == 0 {
+		return Position{}
+	}
+	return t[i-1].Pos
+}
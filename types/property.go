@@ -0,0 +1,56 @@
+package types
+
+import "fmt"
+
+// GetProperty evaluates obj.name. Hashes look name up as a string key and
+// yield NULL when it is missing; host objects resolve it to a field or
+// method.
+func GetProperty(obj Object, name string) (Object, error) {
+	switch obj := obj.(type) {
+	case *Hash:
+		value, ok := obj.Get(&String{Value: name})
+		if !ok {
+			return NULL, nil
+		}
+		return value, nil
+	case *HostObject:
+		return obj.GetProperty(name)
+	default:
+		return nil, fmt.Errorf("type %s has no property %s", obj.Type(), name)
+	}
+}
+
+// SetProperty performs obj.name = value. Hashes are updated in place.
+func SetProperty(obj Object, name string, value Object) error {
+	switch obj := obj.(type) {
+	case *Hash:
+		return obj.Set(&String{Value: name}, value)
+	case *HostObject:
+		return obj.SetProperty(name, value)
+	default:
+		return fmt.Errorf("cannot set property %s on %s", name, obj.Type())
+	}
+}
+
+// SetIndex performs obj[index] = value. Arrays and hashes are reference
+// values, so the change is seen through every variable holding them.
+func SetIndex(obj, index, value Object) error {
+	switch obj := obj.(type) {
+	case *Array:
+		i, ok := index.(*Integer)
+		if !ok {
+			return fmt.Errorf("array index must be INTEGER, got %s", index.Type())
+		}
+		if i.Value < 0 || i.Value >= int64(len(obj.Elements)) {
+			return fmt.Errorf("index out of range: %d with length %d", i.Value, len(obj.Elements))
+		}
+		obj.Elements[i.Value] = value
+		return nil
+	case *Hash:
+		return obj.Set(index, value)
+	case *HostObject:
+		return obj.SetIndex(index, value)
+	default:
+		return fmt.Errorf("index assignment not supported: %s", obj.Type())
+	}
+}
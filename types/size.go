@@ -0,0 +1,125 @@
+package types
+
+// Rough per-object costs, in bytes, on a 64-bit platform. They include the
+// interface box and the Go headers of the object's fields, so that many
+// small values are charged fairly against a memory limit.
+const (
+	objectOverhead    = 16
+	stringOverhead    = objectOverhead + 16
+	sliceOverhead     = objectOverhead + 24
+	pointerSize       = 16 // an Object interface value
+	hashOverhead      = objectOverhead + 48
+	hashEntryOverhead = 72 // HashKey, HashPair and map bucket share
+)
+
+// SizeOf estimates how many bytes obj occupies, not counting the objects it
+// refers to. Callers that charge every allocation therefore charge nested
+// values exactly once.
+func SizeOf(obj Object) int64 {
+	switch obj := obj.(type) {
+	case *String:
+		return int64(stringOverhead + len(obj.Value))
+	case *Array:
+		return int64(sliceOverhead + pointerSize*len(obj.Elements))
+	case *Hash:
+		return int64(hashOverhead + hashEntryOverhead*obj.Len())
+	case *Closure:
+		return int64(sliceOverhead + pointerSize*len(obj.Upvalues))
+	case *Error:
+		return int64(stringOverhead + len(obj.Message))
+	default:
+		return objectOverhead
+	}
+}
+
+// Reachable measures the memory held by a set of roots: the strings, arrays,
+// hashes and closures they lead to, each counted once however many
+// references lead to it. The zero Reachable is empty and ready to use.
+type Reachable struct {
+	seen    map[any]bool
+	pending []any
+	size    int64
+}
+
+// Add adds obj and everything it refers to.
+func (r *Reachable) Add(obj Object) {
+	r.push(obj)
+	r.walk()
+}
+
+// AddEnvironment adds the values bound in env and in the environments it
+// encloses.
+func (r *Reachable) AddEnvironment(env *Environment) {
+	if env != nil {
+		r.push(env)
+		r.walk()
+	}
+}
+
+// Size returns the estimated bytes held by everything added so far.
+func (r *Reachable) Size() int64 {
+	return r.size
+}
+
+// push queues ref to be walked unless it has been seen already. Values that
+// neither hold memory of their own nor refer to other values are skipped.
+func (r *Reachable) push(ref any) {
+	switch ref.(type) {
+	case *String, *Array, *Hash, *Closure, *Function, *ReturnValue, *Environment, *Iterator:
+	default:
+		return
+	}
+	if r.seen[ref] {
+		return
+	}
+	if r.seen == nil {
+		r.seen = make(map[any]bool)
+	}
+	r.seen[ref] = true
+	r.pending = append(r.pending, ref)
+}
+
+func (r *Reachable) walk() {
+	for len(r.pending) > 0 {
+		ref := r.pending[len(r.pending)-1]
+		r.pending = r.pending[:len(r.pending)-1]
+
+		switch ref := ref.(type) {
+		case *String:
+			r.size += SizeOf(ref)
+		case *Array:
+			r.size += SizeOf(ref)
+			for _, element := range ref.Elements {
+				r.push(element)
+			}
+		case *Hash:
+			r.size += SizeOf(ref)
+			for _, pair := range ref.Pairs() {
+				r.push(pair.Key)
+				r.push(pair.Value)
+			}
+		case *Closure:
+			r.size += SizeOf(ref)
+			for _, upvalue := range ref.Upvalues {
+				r.push(upvalue.Get())
+			}
+		case *Function:
+			if ref.Env != nil {
+				r.push(ref.Env)
+			}
+		case *ReturnValue:
+			r.push(ref.Value)
+		case *Iterator:
+			if ref.source != nil {
+				r.push(ref.source)
+			}
+		case *Environment:
+			for _, obj := range ref.store {
+				r.push(obj)
+			}
+			if ref.outer != nil {
+				r.push(ref.outer)
+			}
+		}
+	}
+}
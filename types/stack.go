@@ -0,0 +1,39 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Names shown in stack traces for code that has no declared name.
+const (
+	MainFunctionName      = "<main>"
+	AnonymousFunctionName = "<anonymous>"
+)
+
+// StackFrame is one script call that was active when an error was raised.
+// Pos is where the function was executing: the failing expression in the
+// innermost frame and the pending call in the others.
+type StackFrame struct {
+	Function string
+	Pos      Position
+}
+
+func (f StackFrame) String() string {
+	return fmt.Sprintf("%s (%s)", f.Function, f.Pos)
+}
+
+// FormatStack renders frames one per line, innermost first:
+//
+//	at inner (plugin.pb:3:12)
+//	at <main> (plugin.pb:9:1)
+func FormatStack(frames []StackFrame) string {
+	var out strings.Builder
+	for i, frame := range frames {
+		if i > 0 {
+			out.WriteByte('\n')
+		}
+		out.WriteString("at " + frame.String())
+	}
+	return out.String()
+}